@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+	"github.com/noahxzhu/pushover-notify/internal/web"
+)
+
+// lockRetryInterval is how often an instance on standby tries to take the sender lock over
+const lockRetryInterval = 10 * time.Second
+
+// senderLock keeps the data file lock referenced once this instance holds it. An
+// unreferenced lock would have its file closed by the garbage collector, releasing
+// the flock while this instance is still sending.
+type senderLock struct {
+	mu   sync.Mutex
+	lock *storage.FileLock
+}
+
+func (l *senderLock) set(lock *storage.FileLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lock = lock
+}
+
+// Release gives up the lock, if held; called at shutdown
+func (l *senderLock) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lock != nil {
+		l.lock.Release()
+		l.lock = nil
+	}
+}
+
+// acquireSenderLock makes this instance the only one sending from the data file. If
+// another instance already is, the server goes on standby, read-only and not sending,
+// and keeps trying in the background until the other one stops. The returned lock
+// must be kept until shutdown. A filesystem that can't lock is logged and the
+// instance sends regardless, as before the lock existed.
+func acquireSenderLock(ctx context.Context, dataFile string, srv *web.Server) *senderLock {
+	held := &senderLock{}
+	path := storage.LockPath(dataFile)
+	lock, err := storage.AcquireLock(path)
+	if err == nil {
+		held.set(lock)
+		return held
+	}
+	if !errors.Is(err, storage.ErrLocked) {
+		slog.Warn("Can't lock the data file; make sure no other instance uses it", "error", err)
+		return held
+	}
+
+	slog.Warn("Another instance is sending from this data file; standing by read-only", "lock", path, "holder", storage.LockHolder(path))
+	srv.SetStandby(true)
+	go func() {
+		ticker := time.NewTicker(lockRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			lock, err := storage.AcquireLock(path)
+			if err != nil {
+				if !errors.Is(err, storage.ErrLocked) {
+					slog.Error("Failed to take over the data file lock", "error", err)
+				}
+				continue
+			}
+			held.set(lock)
+			slog.Info("The other instance has stopped; taking over sending", "lock", path)
+			srv.SetStandby(false)
+			return
+		}
+	}()
+	return held
+}
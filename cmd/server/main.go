@@ -15,53 +15,135 @@ import (
 	"github.com/noahxzhu/pushover-notify/internal/worker"
 )
 
+// Build info, injected at build time:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+// configPath is read at startup and again on SIGHUP
+const configPath = "configs/config.yaml"
+
+// HTTP server timeouts used when the config leaves them at 0. Slow or stalled clients
+// can't hold connections open for longer; live updates (SSE) lift the write timeout.
+const (
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 60 * time.Second
+	defaultIdleTimeout  = 120 * time.Second
+)
+
+// orDefault returns d, or def when d is not set
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
 func main() {
 	// Setup structured logger (JSON handler)
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
 	// Load Config
-	cfg, err := config.LoadConfig("configs/config.yaml")
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		slog.Error("Failed to load config", "error", err)
 		os.Exit(1)
 	}
 
 	// Init Storage
-	store := storage.NewStore(cfg.Storage.FilePath)
+	var store storage.Backend
+	switch cfg.Storage.Driver {
+	case "memory":
+		slog.Warn("Using in-memory storage; data will be lost on restart")
+		store = storage.NewInMemoryStore()
+	case "", "json":
+		store = storage.NewStore(cfg.Storage.FilePath, cfg.Storage.AuditFilePath)
+	default:
+		slog.Error("Unknown storage driver", "driver", cfg.Storage.Driver)
+		os.Exit(1)
+	}
 	if err := store.Load(); err != nil {
 		slog.Error("Failed to load storage", "error", err)
 		os.Exit(1)
 	}
+	if problems := store.Validate(); len(problems) > 0 {
+		for _, problem := range problems {
+			slog.Warn("Stored data problem", "problem", problem)
+		}
+		slog.Warn("Stored data has problems; POST /api/integrity as an admin to repair them", "count", len(problems))
+	}
 
 	// Init Worker
+	// Server and worker must agree on whether times keep their seconds
+	keepSeconds := cfg.Worker.SubMinute || cfg.Worker.KeepSeconds
 	w := worker.NewWorker(store)
+	w.SetKeepSeconds(keepSeconds)
+	w.SetStartupDelay(cfg.Worker.StartupDelay)
+
+	// Init Web Server
+	srv := web.NewServer(store, w)
+	srv.SetBuildInfo(web.BuildInfo{Version: version, Commit: commit, BuildTime: buildTime})
+	srv.SetBasePath(cfg.Server.BasePath)
+	srv.SetSubMinute(cfg.Worker.SubMinute)
+	srv.SetKeepSeconds(keepSeconds)
+	srv.SetMaintenance(cfg.Server.Maintenance)
+	if err := applyReloadable(cfg, srv, w, store); err != nil {
+		slog.Error("Invalid config", "error", err)
+		os.Exit(1)
+	}
+	if err := srv.SetCookiePolicy(cfg.Server.CookieSameSite, cfg.Server.TrustProxy); err != nil {
+		slog.Error("Invalid server config", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Server.TemplateDir != "" {
+		srv.SetTemplateDir(cfg.Server.TemplateDir)
+		slog.Info("Loading templates from disk", "dir", cfg.Server.TemplateDir)
+	}
+
+	// Only one instance may send from a data file; a second one stands by
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	held := &senderLock{}
+	if cfg.Storage.Driver != "memory" {
+		held = acquireSenderLock(ctx, cfg.Storage.FilePath, srv)
+	}
 
-	// Start Worker
+	// Start Worker, configured and with the server's callbacks in place
+	w.LogSchedule()
 	go w.Start(ctx)
+	go w.RunWatchdog(ctx)
 
-	// Init Web Server
-	srv := web.NewServer(store, w)
 	httpServer := &http.Server{
-		Addr:    cfg.Server.Port,
-		Handler: srv,
+		Addr:         cfg.Server.Port,
+		Handler:      srv,
+		ReadTimeout:  orDefault(cfg.Server.ReadTimeout, defaultReadTimeout),
+		WriteTimeout: orDefault(cfg.Server.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:  orDefault(cfg.Server.IdleTimeout, defaultIdleTimeout),
 	}
 
 	// Start HTTP Server
 	go func() {
-		slog.Info("Starting server", "port", cfg.Server.Port, "url", "http://localhost"+cfg.Server.Port)
+		slog.Info("Starting server", "port", cfg.Server.Port, "url", "http://localhost"+cfg.Server.Port, "version", version, "commit", commit, "built", buildTime)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			slog.Error("HTTP server error", "error", err)
 			os.Exit(1)
 		}
 	}()
 
-	// Graceful Shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// Reload on SIGHUP; shut down gracefully on SIGINT or SIGTERM
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		cfg = reloadConfig(cfg, srv, w, store)
+	}
 
 	slog.Info("Shutting down...")
 	cancel() // Stop worker
@@ -73,5 +155,6 @@ func main() {
 		slog.Error("Server forced to shutdown", "error", err)
 		os.Exit(1)
 	}
+	held.Release() // Only now may another instance take over sending
 	slog.Info("Server exited")
 }
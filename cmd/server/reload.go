@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/noahxzhu/pushover-notify/internal/config"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+	"github.com/noahxzhu/pushover-notify/internal/web"
+	"github.com/noahxzhu/pushover-notify/internal/worker"
+)
+
+// configSetting names a config key and whether it differs between two configs
+type configSetting struct {
+	key     string
+	changed bool
+}
+
+// changedKeys returns the keys of the settings that changed
+func changedKeys(settings []configSetting) []string {
+	var keys []string
+	for _, s := range settings {
+		if s.changed {
+			keys = append(keys, s.key)
+		}
+	}
+	return keys
+}
+
+// applyReloadable applies the settings that can change while running, at startup
+// and again on SIGHUP. Nothing is applied if the config is invalid.
+func applyReloadable(cfg *config.Config, srv *web.Server, w *worker.Worker, store storage.Backend) error {
+	if err := w.SetProxy(cfg.Pushover.Proxy); err != nil {
+		return fmt.Errorf("pushover.proxy: %w", err)
+	}
+	w.SetAckBaseURL(cfg.Server.PublicURL)
+	w.SetAPIBaseURL(cfg.Pushover.BaseURL)
+	w.SetHistoryLimit(cfg.Worker.HistoryLimit)
+	w.SetOverdueAlertAfter(cfg.Worker.OverdueAlertAfter)
+	w.SetRateLimit(cfg.Pushover.RateLimit, cfg.Pushover.Burst)
+	srv.SetSessionDurations(cfg.Server.SessionDuration, cfg.Server.RememberDuration)
+	srv.SetMaxTotalSends(cfg.Server.MaxTotalSends)
+	srv.SetContentSecurityPolicy(cfg.Server.ContentSecurityPolicy)
+	srv.SetReplies(cfg.Server.Replies)
+	store.SetMaxPending(cfg.Storage.MaxPending)
+	store.SetCompact(cfg.Storage.Compact)
+	return nil
+}
+
+// reloadConfig re-reads the config file and applies what can change without a restart,
+// logging what changed. It returns the config now in effect: the old one if the file
+// can't be read. Templates need no reload: in dev mode they are read from disk on
+// every request.
+func reloadConfig(old *config.Config, srv *web.Server, w *worker.Worker, store storage.Backend) *config.Config {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		slog.Error("Config reload failed; keeping the current config", "error", err)
+		return old
+	}
+
+	if err := applyReloadable(cfg, srv, w, store); err != nil {
+		slog.Error("Config reload failed; keeping the current config", "error", err)
+		return old
+	}
+	// Maintenance mode can also be switched at runtime, so only a change in the file applies
+	if cfg.Server.Maintenance != old.Server.Maintenance {
+		srv.SetMaintenance(cfg.Server.Maintenance)
+	}
+
+	applied := changedKeys([]configSetting{
+		{"server.public_url", cfg.Server.PublicURL != old.Server.PublicURL},
+		{"server.session_duration", cfg.Server.SessionDuration != old.Server.SessionDuration},
+		{"server.remember_duration", cfg.Server.RememberDuration != old.Server.RememberDuration},
+		{"server.max_total_sends", cfg.Server.MaxTotalSends != old.Server.MaxTotalSends},
+		{"server.content_security_policy", cfg.Server.ContentSecurityPolicy != old.Server.ContentSecurityPolicy},
+		{"server.maintenance", cfg.Server.Maintenance != old.Server.Maintenance},
+		{"server.replies", cfg.Server.Replies != old.Server.Replies},
+		{"storage.max_pending", cfg.Storage.MaxPending != old.Storage.MaxPending},
+		{"storage.compact", cfg.Storage.Compact != old.Storage.Compact},
+		{"pushover.base_url", cfg.Pushover.BaseURL != old.Pushover.BaseURL},
+		{"pushover.proxy", cfg.Pushover.Proxy != old.Pushover.Proxy},
+		{"pushover.rate_limit", cfg.Pushover.RateLimit != old.Pushover.RateLimit},
+		{"pushover.burst", cfg.Pushover.Burst != old.Pushover.Burst},
+		{"worker.history_limit", cfg.Worker.HistoryLimit != old.Worker.HistoryLimit},
+		{"worker.overdue_alert_after", cfg.Worker.OverdueAlertAfter != old.Worker.OverdueAlertAfter},
+	})
+	ignored := changedKeys([]configSetting{
+		{"server.port", cfg.Server.Port != old.Server.Port},
+		{"server.base_path", cfg.Server.BasePath != old.Server.BasePath},
+		{"server.template_dir", cfg.Server.TemplateDir != old.Server.TemplateDir},
+		{"server.cookie_samesite", cfg.Server.CookieSameSite != old.Server.CookieSameSite},
+		{"server.trust_proxy", cfg.Server.TrustProxy != old.Server.TrustProxy},
+		{"server.read_timeout", cfg.Server.ReadTimeout != old.Server.ReadTimeout},
+		{"server.write_timeout", cfg.Server.WriteTimeout != old.Server.WriteTimeout},
+		{"server.idle_timeout", cfg.Server.IdleTimeout != old.Server.IdleTimeout},
+		{"storage.driver", cfg.Storage.Driver != old.Storage.Driver},
+		{"storage.file_path", cfg.Storage.FilePath != old.Storage.FilePath},
+		{"storage.audit_file_path", cfg.Storage.AuditFilePath != old.Storage.AuditFilePath},
+		{"worker.sub_minute", cfg.Worker.SubMinute != old.Worker.SubMinute},
+		{"worker.keep_seconds", cfg.Worker.KeepSeconds != old.Worker.KeepSeconds},
+		{"worker.startup_delay", cfg.Worker.StartupDelay != old.Worker.StartupDelay},
+	})
+
+	slog.Info("Config reloaded", "applied", applied)
+	if len(ignored) > 0 {
+		slog.Warn("Config changes that need a restart were not applied", "keys", ignored)
+	}
+
+	// Keep the ignored settings as they are running, so they are reported again next time
+	cfg.Server.Port, cfg.Server.BasePath, cfg.Server.TemplateDir = old.Server.Port, old.Server.BasePath, old.Server.TemplateDir
+	cfg.Server.CookieSameSite, cfg.Server.TrustProxy = old.Server.CookieSameSite, old.Server.TrustProxy
+	cfg.Server.ReadTimeout, cfg.Server.WriteTimeout, cfg.Server.IdleTimeout = old.Server.ReadTimeout, old.Server.WriteTimeout, old.Server.IdleTimeout
+	cfg.Storage.Driver, cfg.Storage.FilePath, cfg.Storage.AuditFilePath = old.Storage.Driver, old.Storage.FilePath, old.Storage.AuditFilePath
+	cfg.Worker.SubMinute, cfg.Worker.KeepSeconds, cfg.Worker.StartupDelay = old.Worker.SubMinute, old.Worker.KeepSeconds, old.Worker.StartupDelay
+	return cfg
+}
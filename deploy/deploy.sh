@@ -8,7 +8,11 @@ cd "$(dirname "$0")/.."
 IMAGE_NAME="pushover-notify:latest"
 
 echo ">>> 1. Building container image..."
-podman build -t $IMAGE_NAME -f Containerfile .
+podman build -t $IMAGE_NAME -f Containerfile \
+    --build-arg VERSION="$(git describe --tags --always --dirty 2>/dev/null || echo dev)" \
+    --build-arg COMMIT="$(git rev-parse --short HEAD 2>/dev/null || echo unknown)" \
+    --build-arg BUILD_TIME="$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
+    .
 
 echo ">>> 2. Installing Quadlet service file..."
 SYSTEMD_DIR="$HOME/.config/containers/systemd"
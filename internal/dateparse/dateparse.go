@@ -0,0 +1,258 @@
+package dateparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result is the interpretation of a quick-add phrase
+type Result struct {
+	Time    time.Time
+	Content string
+}
+
+// Default hour used when a day is given without a time ("tomorrow buy milk")
+const defaultHour = 9
+
+var (
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	durationPattern = regexp.MustCompile(`^(\d+)(m|min|mins|h|hr|hrs|d|w)$`)
+	isoDatePattern  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Parse extracts a date/time from phrases like "tomorrow 9am buy milk",
+// "in 2 hours call mom" or "pay rent fri at 18:30". Recognized date and
+// time words may appear anywhere; the remaining words become the content.
+func Parse(input string, now time.Time) (Result, error) {
+	words := strings.Fields(input)
+	used := make([]bool, len(words))
+
+	var (
+		day     time.Time // Midnight of the chosen day, zero if none given
+		hour    = -1
+		minute  int
+		offset  time.Duration
+		hasTime bool
+	)
+
+	lower := func(i int) string {
+		return strings.ToLower(strings.Trim(words[i], ",."))
+	}
+
+	for i := 0; i < len(words); i++ {
+		w := lower(i)
+
+		switch {
+		case w == "today" && day.IsZero():
+			day = midnight(now)
+			used[i] = true
+
+		case w == "tonight" && day.IsZero():
+			day = midnight(now)
+			if hour < 0 {
+				hour = 20
+			}
+			used[i] = true
+
+		case w == "tomorrow" && day.IsZero():
+			day = midnight(now).AddDate(0, 0, 1)
+			used[i] = true
+
+		case (w == "noon" || w == "midnight") && !hasTime:
+			hour, minute, hasTime = 12, 0, true
+			if w == "midnight" {
+				hour = 0
+			}
+			used[i] = true
+
+		case isoDatePattern.MatchString(w) && day.IsZero():
+			d, err := time.ParseInLocation("2006-01-02", w, now.Location())
+			if err != nil {
+				continue
+			}
+			day = d
+			used[i] = true
+
+		case w == "in" && offset == 0 && i+1 < len(words):
+			// "in 30 minutes", "in 2h"
+			if d, n := parseOffset(words[i+1:]); n > 0 {
+				offset = d
+				for j := i; j <= i+n; j++ {
+					used[j] = true
+				}
+				i += n
+			}
+
+		case w == "at" && !hasTime && i+1 < len(words):
+			// "at 5" allows a bare hour; elsewhere numbers are left as content
+			if h, m, n, ok := parseClock(words[i+1:], true); ok {
+				hour, minute, hasTime = h, m, true
+				for j := i; j <= i+n; j++ {
+					used[j] = true
+				}
+				i += n
+			}
+
+		case w == "next" && day.IsZero() && i+1 < len(words):
+			if wd, ok := weekdays[lower(i+1)]; ok {
+				day = nextWeekday(now, wd)
+				used[i], used[i+1] = true, true
+				i++
+			}
+
+		default:
+			if wd, ok := weekdays[w]; ok && day.IsZero() {
+				day = nextWeekday(now, wd)
+				used[i] = true
+				continue
+			}
+			if !hasTime {
+				if h, m, n, ok := parseClock(words[i:], false); ok {
+					hour, minute, hasTime = h, m, true
+					for j := i; j < i+n; j++ {
+						used[j] = true
+					}
+					i += n - 1
+				}
+			}
+		}
+	}
+
+	var content []string
+	for i, w := range words {
+		if !used[i] {
+			content = append(content, w)
+		}
+	}
+	res := Result{Content: strings.Join(content, " ")}
+	if res.Content == "" {
+		return Result{}, fmt.Errorf("no reminder text found")
+	}
+
+	switch {
+	case offset > 0:
+		res.Time = now.Add(offset)
+	case !day.IsZero():
+		if hour < 0 {
+			hour = defaultHour
+		}
+		res.Time = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	case hour >= 0:
+		res.Time = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+		if !res.Time.After(now) {
+			res.Time = res.Time.AddDate(0, 0, 1)
+		}
+	default:
+		return Result{}, fmt.Errorf("no date or time found")
+	}
+
+	return res, nil
+}
+
+// parseClock reads a time of day from the start of words and reports how many words it used.
+// Bare hours ("5") are only accepted when allowBare is set.
+func parseClock(words []string, allowBare bool) (hour, minute, n int, ok bool) {
+	w := strings.ToLower(strings.Trim(words[0], ",."))
+	n = 1
+	// Join a separated meridiem: "9 am"
+	if len(words) > 1 {
+		next := strings.ToLower(strings.Trim(words[1], ",."))
+		if (next == "am" || next == "pm") && clockPattern.MatchString(w) {
+			w += next
+			n = 2
+		}
+	}
+
+	matches := clockPattern.FindStringSubmatch(w)
+	if matches == nil {
+		return 0, 0, 0, false
+	}
+	hasMinutes, meridiem := matches[2] != "", matches[3]
+	if !hasMinutes && meridiem == "" && !allowBare {
+		return 0, 0, 0, false
+	}
+
+	hour, _ = strconv.Atoi(matches[1])
+	if hasMinutes {
+		minute, _ = strconv.Atoi(matches[2])
+	}
+	if minute > 59 {
+		return 0, 0, 0, false
+	}
+
+	switch meridiem {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0, false
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, 0, false
+		}
+	}
+	return hour, minute, n, true
+}
+
+// parseOffset reads "30 minutes", "2 hours" or "2h" and reports how many words it used
+func parseOffset(words []string) (time.Duration, int) {
+	w := strings.ToLower(strings.Trim(words[0], ",."))
+	if m := durationPattern.FindStringSubmatch(w); m != nil {
+		value, _ := strconv.Atoi(m[1])
+		return unitDuration(m[2]) * time.Duration(value), 1
+	}
+
+	value, err := strconv.Atoi(w)
+	if err != nil || value <= 0 || len(words) < 2 {
+		return 0, 0
+	}
+	unit := strings.ToLower(strings.Trim(words[1], ",."))
+	d := unitDuration(unit)
+	if d == 0 {
+		return 0, 0
+	}
+	return d * time.Duration(value), 2
+}
+
+func unitDuration(unit string) time.Duration {
+	switch strings.TrimSuffix(unit, "s") {
+	case "m", "min", "minute":
+		return time.Minute
+	case "h", "hr", "hour":
+		return time.Hour
+	case "d", "day":
+		return 24 * time.Hour
+	case "w", "week":
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// nextWeekday returns midnight of the next wd strictly after today
+func nextWeekday(now time.Time, wd time.Weekday) time.Time {
+	days := (int(wd) - int(now.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return midnight(now).AddDate(0, 0, days)
+}
@@ -0,0 +1,57 @@
+package i18n
+
+var de = map[string]string{
+	"nav.audit":    "Protokoll",
+	"nav.settings": "Einstellungen",
+	"nav.logout":   "Abmelden",
+
+	"login.subtitle":             "Melden Sie sich an, um Ihre Benachrichtigungen zu verwalten",
+	"login.username":             "Benutzername",
+	"login.username_placeholder": "Benutzername eingeben",
+	"login.password":             "Passwort",
+	"login.password_placeholder": "Passwort eingeben",
+	"login.remember":             "Angemeldet bleiben",
+	"login.submit":               "Anmelden",
+	"login.invalid":              "Benutzername oder Passwort ist falsch",
+
+	"index.quick_add":        "Schnell hinzufügen",
+	"index.add_notification": "Benachrichtigung hinzufügen",
+	"index.scheduled":        "Geplante Benachrichtigungen",
+	"index.api_access":       "API-Zugriff",
+	"index.calendar_feed":    "Kalender-Feed",
+
+	"language.title": "Sprache",
+	"language.help":  "Sprache der Weboberfläche. Automatisch folgt Ihrem Browser.",
+	"language.auto":  "Automatisch",
+	"language.save":  "Speichern",
+
+	"maintenance.title": "Wartungsmodus",
+	"maintenance.text":  "Änderungen sind gesperrt und es werden keine Benachrichtigungen gesendet, bis er endet.",
+	"maintenance.end":   "Wartung beenden",
+
+	"standby.title": "Bereitschaft",
+	"standby.text":  "eine andere Instanz sendet aus derselben Datendatei, daher ist diese schreibgeschützt. Sie übernimmt, sobald die andere endet.",
+
+	"format.datetime":         "02.01.2006 15:04",
+	"format.datetime_seconds": "02.01.2006 15:04:05",
+	"format.weekday_datetime": "Mon, 02.01.2006 15:04",
+	"format.short":            "02.01. 15:04",
+	"format.short_seconds":    "02.01. 15:04:05",
+	"format.time":             "15:04",
+	"format.time_seconds":     "15:04:05",
+	"format.thousands":        ".",
+
+	"weekday.0": "So",
+	"weekday.1": "Mo",
+	"weekday.2": "Di",
+	"weekday.3": "Mi",
+	"weekday.4": "Do",
+	"weekday.5": "Fr",
+	"weekday.6": "Sa",
+
+	"error.not_found":   "Nicht gefunden",
+	"error.read_only":   "Verboten: Konto nur mit Lesezugriff",
+	"error.maintenance": "Wartungsmodus: Änderungen sind vorübergehend gesperrt, bitte später erneut versuchen",
+	"error.standby":     "Bereitschaft: eine andere Instanz sendet, bitte Änderungen dort vornehmen",
+	"error.locale":      "Nicht unterstützte Sprache",
+}
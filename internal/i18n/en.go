@@ -0,0 +1,57 @@
+package i18n
+
+var en = map[string]string{
+	"nav.audit":    "Audit",
+	"nav.settings": "Settings",
+	"nav.logout":   "Logout",
+
+	"login.subtitle":             "Sign in to manage your notifications",
+	"login.username":             "Username",
+	"login.username_placeholder": "Enter your username",
+	"login.password":             "Password",
+	"login.password_placeholder": "Enter your password",
+	"login.remember":             "Remember me",
+	"login.submit":               "Sign In",
+	"login.invalid":              "Invalid username or password",
+
+	"index.quick_add":        "Quick Add",
+	"index.add_notification": "Add Notification",
+	"index.scheduled":        "Scheduled Notifications",
+	"index.api_access":       "API Access",
+	"index.calendar_feed":    "Calendar Feed",
+
+	"language.title": "Language",
+	"language.help":  "Language of the web interface. Automatic follows your browser.",
+	"language.auto":  "Automatic",
+	"language.save":  "Save",
+
+	"maintenance.title": "Maintenance mode",
+	"maintenance.text":  "changes are disabled and no notifications are sent until it ends.",
+	"maintenance.end":   "End maintenance",
+
+	"standby.title": "Standby",
+	"standby.text":  "another instance is sending from the same data file, so this one is read-only. It takes over when the other stops.",
+
+	"format.datetime":         "2006-01-02 03:04 PM",
+	"format.datetime_seconds": "2006-01-02 03:04:05 PM",
+	"format.weekday_datetime": "Mon 2006-01-02 03:04 PM",
+	"format.short":            "Jan 2 03:04 PM",
+	"format.short_seconds":    "Jan 2 03:04:05 PM",
+	"format.time":             "03:04 PM",
+	"format.time_seconds":     "03:04:05 PM",
+	"format.thousands":        ",",
+
+	"weekday.0": "Sun",
+	"weekday.1": "Mon",
+	"weekday.2": "Tue",
+	"weekday.3": "Wed",
+	"weekday.4": "Thu",
+	"weekday.5": "Fri",
+	"weekday.6": "Sat",
+
+	"error.not_found":   "Not found",
+	"error.read_only":   "Forbidden: read-only account",
+	"error.maintenance": "Maintenance mode: changes are disabled for now, try again later",
+	"error.standby":     "Standby: another instance is the sender, make changes there",
+	"error.locale":      "Unsupported language",
+}
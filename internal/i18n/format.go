@@ -0,0 +1,36 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatTime formats t with the locale's layout for style, e.g. "datetime" or "time";
+// the layouts are the catalog's "format.*" entries. Go only knows English weekday
+// names, so a "Mon" in the layout is filled in from the catalog's "weekday.*" entries.
+func FormatTime(locale, style string, t time.Time) string {
+	parts := strings.Split(T(locale, "format."+style), "Mon")
+	for i, part := range parts {
+		parts[i] = t.Format(part)
+	}
+	return strings.Join(parts, T(locale, "weekday."+strconv.Itoa(int(t.Weekday()))))
+}
+
+// FormatInt formats n with the locale's thousands separator, e.g. 1,234 or 1.234
+func FormatInt(locale string, n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	sep := T(locale, "format.thousands")
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
@@ -0,0 +1,81 @@
+// Package i18n holds the UI message catalogs and picks a locale for a request.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the locale used when nothing better matches; its catalog is complete
+const Default = "en"
+
+// Locale is a supported locale and its name in that language
+type Locale struct {
+	Code string
+	Name string
+}
+
+// Locales lists the supported locales, the default first
+var Locales = []Locale{
+	{Code: "en", Name: "English"},
+	{Code: "de", Name: "Deutsch"},
+}
+
+var catalogs = map[string]map[string]string{
+	"en": en,
+	"de": de,
+}
+
+// Supported reports whether code is one of Locales
+func Supported(code string) bool {
+	_, ok := catalogs[code]
+	return ok
+}
+
+// T returns the message for key in locale, formatted with args if any. Keys missing from
+// the locale fall back to the default catalog, and then to the key itself.
+func T(locale, key string, args ...any) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		if msg, ok = catalogs[Default][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Match picks the supported locale the Accept-Language header prefers most, comparing
+// primary language subtags only (so "de-AT" gets "de"), or Default if none match
+func Match(acceptLanguage string) string {
+	type choice struct {
+		code string
+		q    float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if Supported(primary) && q > 0 {
+			choices = append(choices, choice{primary, q})
+		}
+	}
+	if len(choices) == 0 {
+		return Default
+	}
+	// Stable, so equally weighted languages keep the header's order
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	return choices[0].code
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditCreate     AuditAction = "create"
+	AuditUpdate     AuditAction = "update"
+	AuditDelete     AuditAction = "delete"
+	AuditSend       AuditAction = "send"
+	AuditSendFailed AuditAction = "send_failed"
+	AuditAck        AuditAction = "ack"
+	AuditReply      AuditAction = "reply"
+)
+
+// AuditEvent is a single entry in the append-only audit log
+type AuditEvent struct {
+	Time           time.Time   `json:"time"`
+	Action         AuditAction `json:"action"`
+	NotificationID string      `json:"notification_id"`
+	Content        string      `json:"content,omitempty"`
+	Actor          string      `json:"actor,omitempty"` // "session:<prefix>" for web requests, "worker" for sends
+	Detail         string      `json:"detail,omitempty"`
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// MaxValues bounds the template values a notification may carry
+const MaxValues = 20
+
+var valueNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsTemplate reports whether content is a template to fill in with values, which is
+// whenever it contains "{{"
+func IsTemplate(content string) bool {
+	return strings.Contains(content, "{{")
+}
+
+// MaxMessageLength is the longest message Pushover accepts, in characters
+const MaxMessageLength = 1024
+
+// errTooLong stops a template whose output runs past MaxMessageLength
+var errTooLong = fmt.Errorf("Content is longer than %d characters once filled in", MaxMessageLength)
+
+// RenderContent fills in a content template like "Pay {{.Amount}} rent" with values.
+// Content without "{{" is returned as it is. Every name used must have a value, and
+// {{.Name}} is all a template may contain: no functions, conditions or loops, so
+// content can't run up the server's time or memory. Output past MaxMessageLength is
+// an error.
+func RenderContent(content string, values map[string]string) (string, error) {
+	if !IsTemplate(content) {
+		return content, nil
+	}
+	// Parsed without any functions, the builtins included
+	trees, err := parse.Parse("content", content, "{{", "}}", map[string]any{})
+	if err != nil {
+		return "", fmt.Errorf("Invalid content template: %w", err)
+	}
+	tree := trees["content"]
+	if tree == nil || len(trees) > 1 {
+		return "", errors.New("Invalid content template: only values like {{.Name}} can be filled in")
+	}
+	if err := checkValueLookups(tree.Root); err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("content").Option("missingkey=error").AddParseTree("content", tree)
+	if err != nil {
+		return "", fmt.Errorf("Invalid content template: %w", err)
+	}
+	b := &limitedBuilder{limit: MaxMessageLength}
+	if err := tmpl.Execute(b, values); err != nil {
+		if errors.Is(err, errTooLong) {
+			return "", errTooLong
+		}
+		return "", fmt.Errorf("Content template failed: %w", err)
+	}
+	return b.String(), nil
+}
+
+// checkValueLookups rejects any part of a template other than text and {{.Name}}
+func checkValueLookups(list *parse.ListNode) error {
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			continue
+		case *parse.ActionNode:
+			if isValueLookup(node.Pipe) {
+				continue
+			}
+		}
+		return fmt.Errorf("Invalid content template: %s: only values like {{.Name}} can be filled in", node)
+	}
+	return nil
+}
+
+// isValueLookup reports whether pipe is a single field like .Name and nothing else
+func isValueLookup(pipe *parse.PipeNode) bool {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	return ok && len(field.Ident) == 1
+}
+
+// limitedBuilder collects template output up to limit characters, failing with
+// errTooLong past that
+type limitedBuilder struct {
+	strings.Builder
+	limit int
+	count int
+}
+
+func (b *limitedBuilder) Write(p []byte) (int, error) {
+	b.count += utf8.RuneCount(p)
+	if b.count > b.limit {
+		return 0, errTooLong
+	}
+	return b.Builder.Write(p)
+}
+
+// RenderContent returns the message text for n, its content filled in with its values
+func (n *Notification) RenderContent() (string, error) {
+	return RenderContent(n.Content, n.Values)
+}
+
+// ParseValues reads template values written one per line as "Name=value". Blank lines
+// are skipped; names must be identifiers so templates can use them as {{.Name}}.
+func ParseValues(text string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !valueNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("Invalid value %q: write each as Name=value", line)
+		}
+		values[name] = strings.TrimSpace(value)
+	}
+	if len(values) > MaxValues {
+		return nil, fmt.Errorf("Too many values (max %d)", MaxValues)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// ValuesText formats n's values as ParseValues reads them, sorted by name
+func (n *Notification) ValuesText() string {
+	names := make([]string, 0, len(n.Values))
+	for name := range n.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + "=" + n.Values[name]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderContent(t *testing.T) {
+	values := map[string]string{"Amount": "$1,200", "Who": "Sam"}
+	got, err := RenderContent("Pay {{.Amount}} to {{ .Who }}", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Pay $1,200 to Sam" {
+		t.Errorf("rendered %q", got)
+	}
+	if _, err := RenderContent("Pay {{.Missing}}", values); err == nil {
+		t.Error("missing value rendered")
+	}
+}
+
+func TestRenderContentOnlyValueLookups(t *testing.T) {
+	values := map[string]string{"Amount": "1"}
+	for _, content := range []string{
+		`{{printf "%0999999d" 1}}`,
+		`{{len .Amount}}`,
+		`{{.Amount | html}}`,
+		`{{if .Amount}}x{{end}}`,
+		`{{range .}}x{{end}}`,
+		`{{$x := .Amount}}`,
+		`{{.}}`,
+		`{{"text"}}`,
+		`{{define "a"}}x{{end}}{{template "a"}}`,
+		`{{template "content"}}`,
+	} {
+		if got, err := RenderContent(content, values); err == nil {
+			t.Errorf("%s rendered %q", content, got)
+		}
+	}
+}
+
+func TestRenderContentLength(t *testing.T) {
+	long := map[string]string{"Text": strings.Repeat("é", MaxMessageLength)}
+	if got, err := RenderContent("{{.Text}}", long); err != nil || len([]rune(got)) != MaxMessageLength {
+		t.Errorf("%d characters rendered %d, %v", MaxMessageLength, len([]rune(got)), err)
+	}
+	if _, err := RenderContent("!{{.Text}}", long); err == nil {
+		t.Error("content past the message limit rendered")
+	}
+	if _, err := RenderContent(strings.Repeat("{{.Text}}", 100), long); err == nil {
+		t.Error("repeated values past the message limit rendered")
+	}
+}
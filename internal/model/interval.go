@@ -0,0 +1,40 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var intervalPattern = regexp.MustCompile(`^(\d+)([smhd])$`)
+
+// ParseInterval converts a repeat interval like "10s", "30m", "2h" or "1d" to a duration.
+// Unlike time.ParseDuration it understands days.
+func ParseInterval(interval string) (time.Duration, error) {
+	matches := intervalPattern.FindStringSubmatch(interval)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	value, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	unit := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}[matches[2]]
+	return time.Duration(value) * unit, nil
+}
+
+// IntervalDays returns the number of days in an interval given in days, like "1d" or
+// "7d". Such intervals are calendar-based: they repeat at the same wall-clock time,
+// which across a daylight saving change is 23 or 25 hours later rather than 24.
+func IntervalDays(interval string) (int, bool) {
+	matches := intervalPattern.FindStringSubmatch(interval)
+	if matches == nil || matches[2] != "d" {
+		return 0, false
+	}
+	days, err := strconv.Atoi(matches[1])
+	if err != nil || days <= 0 {
+		return 0, false
+	}
+	return days, true
+}
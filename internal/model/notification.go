@@ -1,6 +1,10 @@
 package model
 
-import "time"
+import (
+	"net/url"
+	"slices"
+	"time"
+)
 
 type SendStatus string
 
@@ -10,22 +14,295 @@ const (
 )
 
 type Notification struct {
-	ID             string     `json:"id"`
-	Content        string     `json:"content"`
-	ScheduledTime  time.Time  `json:"scheduled_time"`
-	Status         SendStatus `json:"status"`
-	SendsCount     int        `json:"sends_count"`
-	LastPushTime   time.Time  `json:"last_push_time"`
-	RepeatTimes    int        `json:"repeat_times"`
-	RepeatInterval string     `json:"repeat_interval"`
+	ID             string            `json:"id"`
+	Content        string            `json:"content"`
+	Values         map[string]string `json:"values,omitempty"` // Filled into Content when it is a template, e.g. {{.Amount}}
+	ScheduledTime  time.Time         `json:"scheduled_time"`
+	Status         SendStatus        `json:"status"`
+	SendsCount     int               `json:"sends_count"` // Sends made so far
+	LastPushTime   time.Time         `json:"last_push_time"`
+	TotalSends     int               `json:"total_sends"` // Sends in the series, the first included; was "repeat_times"
+	RepeatInterval string            `json:"repeat_interval"`
+	AckToken       string            `json:"ack_token,omitempty"` // Set when the reminder carries a web acknowledge link
+	AcknowledgedAt time.Time         `json:"acknowledged_at"`
+	OwnerID        string            `json:"owner_id,omitempty"` // User who created it; empty for legacy data (admins only)
+	// StopOnFirstDelivery sends the reminder once, ignoring TotalSends
+	StopOnFirstDelivery bool `json:"stop_on_first_delivery,omitempty"`
+	// ImageURL is fetched at send time and attached to the message
+	ImageURL string `json:"image_url,omitempty"`
+	// RepeatUntil, when set, replaces TotalSends: repeats continue at the interval
+	// until this time has passed
+	RepeatUntil time.Time `json:"repeat_until,omitzero"`
+	// Priority is the Pushover priority, -2 (lowest) to 2 (emergency)
+	Priority int `json:"priority,omitempty"`
+	// Escalation, when set, gives the priority of each send in turn instead of
+	// Priority; sends past the end of the list use its last entry
+	Escalation []int `json:"escalation,omitempty"`
+	// AutoDeleteAfter, when set, removes the notification this long after it is Done
+	AutoDeleteAfter time.Duration `json:"auto_delete_after,omitempty"`
+	// SendWindowStart and SendWindowEnd ("15:04"), when set, limit sends to that time
+	// of day; sends falling outside wait for the window to open. An end before the
+	// start spans midnight, e.g. 22:00 to 06:00.
+	SendWindowStart string `json:"send_window_start,omitempty"`
+	SendWindowEnd   string `json:"send_window_end,omitempty"`
+	// WeekdaysOnly moves a send falling on a Saturday, Sunday or one of
+	// Settings.Holidays to the same time on the next working day
+	WeekdaysOnly bool `json:"weekdays_only,omitempty"`
+	// SendOnStartup sends the reminder once more whenever the app starts, outside its
+	// series; StartupSentAt is when that last happened, to hold off quick restarts
+	SendOnStartup bool      `json:"send_on_startup,omitempty"`
+	StartupSentAt time.Time `json:"startup_sent_at,omitzero"`
+	// Place and Coordinates say where the reminder is about; messages link to a map of
+	// them. Coordinates are "lat,long"; without them the map searches for Place.
+	Place       string `json:"place,omitempty"`
+	Coordinates string `json:"coordinates,omitempty"`
+	// Pinned notifications are listed first
+	Pinned bool `json:"pinned,omitempty"`
+	// LabelID refers to one of Settings.Labels
+	LabelID string `json:"label_id,omitempty"`
+	// GroupID ties related notifications together so they can be paused or deleted
+	// as one. GroupName is repeated on every member; groups have no record of their own.
+	GroupID   string `json:"group_id,omitempty"`
+	GroupName string `json:"group_name,omitempty"`
+	// Paused notifications are skipped by the worker until resumed
+	Paused bool `json:"paused,omitempty"`
+	// LastError is why the latest send attempt failed; cleared by a successful send
+	LastError string `json:"last_error,omitempty"`
+	// RetryAt holds the next send back after a temporary failure; cleared by a successful send
+	RetryAt time.Time `json:"retry_at,omitzero"`
+	// Receipts are Pushover's receipts for the latest emergency sends, newest last, so
+	// an acknowledgement callback can be matched back to the notification
+	Receipts []string `json:"receipts,omitempty"`
+	// History is the latest send attempts, oldest first, bounded by the worker
+	History []SendAttempt `json:"history,omitempty"`
+	// ReplyToken is quoted in messages while replies are enabled, so a reply posted
+	// back to /reply can be matched to the notification
+	ReplyToken string `json:"reply_token,omitempty"`
+	// SnoozedUntil holds the next send back after a "snooze" reply
+	SnoozedUntil time.Time `json:"snoozed_until,omitzero"`
+}
+
+// SendAttempt records one try at sending a notification
+type SendAttempt struct {
+	Time  time.Time `json:"time"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+}
+
+// RecordAttempt appends a to the history, dropping the oldest attempts beyond limit.
+// The history is copied rather than appended to in place, so n may be a copy of a
+// notification others are still reading.
+func (n *Notification) RecordAttempt(a SendAttempt, limit int) {
+	n.History = append(slices.Clip(n.History), a)
+	n.TrimHistory(limit)
+}
+
+// TrimHistory drops the oldest attempts beyond limit and reports whether any were
+func (n *Notification) TrimHistory(limit int) bool {
+	if len(n.History) <= limit {
+		return false
+	}
+	n.History = append([]SendAttempt(nil), n.History[len(n.History)-limit:]...)
+	return true
+}
+
+// maxReceipts is how many emergency receipts a notification keeps. Pushover stops
+// retrying an emergency message after an hour, so older ones are rarely acknowledged.
+const maxReceipts = 5
+
+// AddReceipt records the receipt of an emergency send, dropping the oldest beyond
+// maxReceipts. Like RecordAttempt, it leaves the old receipts as they were.
+func (n *Notification) AddReceipt(receipt string) {
+	n.Receipts = append(slices.Clip(n.Receipts), receipt)
+	if len(n.Receipts) > maxReceipts {
+		n.Receipts = append([]string(nil), n.Receipts[len(n.Receipts)-maxReceipts:]...)
+	}
+}
+
+// DoneAt is when a Done notification completed: its last send, or the
+// acknowledgement if that came later
+func (n *Notification) DoneAt() time.Time {
+	if n.AcknowledgedAt.After(n.LastPushTime) {
+		return n.AcknowledgedAt
+	}
+	return n.LastPushTime
+}
+
+// MapURL links to n's location on Google Maps, or is empty when it has none
+func (n *Notification) MapURL() string {
+	switch {
+	case n.Coordinates != "":
+		return "https://maps.google.com/?q=" + n.Coordinates
+	case n.Place != "":
+		return "https://maps.google.com/?q=" + url.QueryEscape(n.Place)
+	}
+	return ""
+}
+
+// NextInSendWindow returns the earliest time at or after t within n's send window,
+// in t's location; t itself when n has no window
+func (n *Notification) NextInSendWindow(t time.Time) time.Time {
+	start, err1 := time.Parse("15:04", n.SendWindowStart)
+	end, err2 := time.Parse("15:04", n.SendWindowEnd)
+	if err1 != nil || err2 != nil {
+		return t
+	}
+	spansMidnight := !end.After(start)
+
+	// The window holding t opened today or, when it spans midnight, possibly yesterday
+	for day := -1; day <= 1; day++ {
+		opens := time.Date(t.Year(), t.Month(), t.Day()+day, start.Hour(), start.Minute(), 0, 0, t.Location())
+		endDay := day
+		if spansMidnight {
+			endDay++
+		}
+		closes := time.Date(t.Year(), t.Month(), t.Day()+endDay, end.Hour(), end.Minute(), 0, 0, t.Location())
+		if t.Before(opens) {
+			return opens
+		}
+		if t.Before(closes) {
+			return t
+		}
+	}
+	return t // Unreachable: tomorrow's window opens after t
+}
+
+// maxWorkdaySearch bounds how far ahead NextWorkday looks, should every day be a holiday
+const maxWorkdaySearch = 400
+
+// NextWorkday returns t or, when n is weekdays-only and t falls on a weekend or one of
+// holidays ("2006-01-02") in the server's time zone, the same time of day on the next
+// day that is neither
+func (n *Notification) NextWorkday(t time.Time, holidays []string) time.Time {
+	if !n.WeekdaysOnly {
+		return t
+	}
+	local := t.In(time.Local)
+	for i := range maxWorkdaySearch {
+		day := local.AddDate(0, 0, i)
+		weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+		if !weekend && !slices.Contains(holidays, day.Format("2006-01-02")) {
+			if i == 0 {
+				return t
+			}
+			return day
+		}
+	}
+	return t
+}
+
+// LastSentAt is when the latest successful send was made, or zero if there was none
+func (n *Notification) LastSentAt() time.Time {
+	for i := len(n.History) - 1; i >= 0; i-- {
+		if n.History[i].OK {
+			return n.History[i].Time
+		}
+	}
+	if n.SendsCount > 0 && n.LastError == "" {
+		return n.LastPushTime // Sent before the history was kept
+	}
+	return time.Time{}
+}
+
+// SeriesLength is the number of sends in the series under the given send mode
+func (n *Notification) SeriesLength(mode string) int {
+	count := n.TotalSends
+	if count == 0 {
+		count = 3 // Default for legacy data
+	}
+	if mode == SendModeRepeats {
+		count++ // The initial send doesn't count as a repeat
+	}
+	return count
 }
 
 type Settings struct {
-	PushoverToken  string `json:"pushover_token"`
-	PushoverUser   string `json:"pushover_user"`
-	RepeatTimes    int    `json:"repeat_times"`
-	RepeatInterval string `json:"repeat_interval"` // Duration string e.g. "30m"
-	Password       string `json:"password"`        // Plain text
+	PushoverToken  string    `json:"pushover_token"`
+	PushoverUser   string    `json:"pushover_user"`
+	TotalSends     int       `json:"total_sends"`     // Default for new notifications; was "repeat_times"
+	RepeatInterval string    `json:"repeat_interval"` // Duration string e.g. "30m"
+	Password       string    `json:"password"`        // Legacy plain text; migrated to Users on first login
+	Users          []User    `json:"users"`           // Web UI accounts
+	Labels         []Label   `json:"labels"`          // Colored labels notifications can be tagged with
+	MutedUntil     time.Time `json:"muted_until"`     // Global mute; sends are deferred until this time
+	DefaultTitle   string    `json:"default_title"`   // Message title, e.g. "Reminder"
+
+	// Spread sends due in the same minute over this many seconds; 0 sends on the minute
+	JitterSeconds int `json:"jitter_seconds,omitempty"`
+	// SendMode says how TotalSends is counted, SendModeTotal (the default) or SendModeRepeats
+	SendMode string `json:"send_mode,omitempty"`
+	// DedupeMode says what happens to a new notification with the same owner, content
+	// and scheduled time as a pending one: DedupeOff (the default), DedupeReject or DedupeMerge
+	DedupeMode string `json:"dedupe_mode,omitempty"`
+	// Sound is the Pushover sound for every message; empty uses the device's default
+	Sound string `json:"sound,omitempty"`
+	// MinLeadMinutes rejects new notifications scheduled less than this far ahead; 0 allows any time
+	MinLeadMinutes int `json:"min_lead_minutes,omitempty"`
+	// FallbackWebhookURL gets a JSON alert when sends keep failing; empty sends none
+	FallbackWebhookURL string `json:"fallback_webhook_url,omitempty"`
+	// InstanceName prefixes every message title, e.g. "[Home] Reminder", to tell
+	// instances apart; empty adds nothing
+	InstanceName string `json:"instance_name,omitempty"`
+	// Holidays are dates ("2006-01-02"), sorted, that weekdays-only notifications skip
+	// like weekends
+	Holidays []string `json:"holidays,omitempty"`
+}
+
+// Send modes: how a notification's TotalSends is counted
+const (
+	SendModeTotal   = "total"   // N sends in all, the first included
+	SendModeRepeats = "repeats" // A send at the scheduled time, then N repeats
+)
+
+// Dedupe modes: how a duplicate of a pending notification is handled when added
+const (
+	DedupeOff    = ""       // Added like any other
+	DedupeReject = "reject" // Refused with the existing one's ID
+	DedupeMerge  = "merge"  // Dropped in favor of the existing one
+)
+
+// List orders: how a user's notifications list is sorted. Pinned notifications come
+// first in each, and groups are listed together at their first member.
+const (
+	ListOrderScheduled = ""         // Scheduled time, earliest first
+	ListOrderNextDue   = "next_due" // Pending by next send, soonest first, then Done, latest first
+	ListOrderNewest    = "newest"   // Most recently added first
+)
+
+// Label is a named color shown as a badge on the notifications tagged with it
+type Label struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"` // Hex, e.g. "#3b82f6"
+}
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // Full access to everything
+	RoleUser   Role = "user"   // Manages their own notifications
+	RoleViewer Role = "viewer" // Read-only view of their own notifications
+)
+
+type User struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	PasswordHash   string `json:"password_hash"`
+	Role           Role   `json:"role"`
+	SessionVersion int    `json:"session_version,omitempty"` // Bumped on password change to invalidate existing sessions
+	CalendarToken  string `json:"calendar_token,omitempty"`  // Secret for the read-only calendar feed
+	APIKey         string `json:"api_key,omitempty"`         // Bearer key for the /api/v1 endpoints
+	Locale         string `json:"locale,omitempty"`          // UI language; empty follows the browser's Accept-Language
+	ListOrder      string `json:"list_order,omitempty"`      // One of the ListOrder constants
+}
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// CanWrite reports whether the user may create and edit notifications
+func (u User) CanWrite() bool {
+	return u.Role == RoleAdmin || u.Role == RoleUser
 }
 
 type AppSchema struct {
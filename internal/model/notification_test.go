@@ -0,0 +1,28 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZeroTimesOmitted(t *testing.T) {
+	data, err := json.Marshal(Notification{ID: "n1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"retry_at", "snoozed_until", "repeat_until", "startup_sent_at"} {
+		if strings.Contains(string(data), `"`+field+`"`) {
+			t.Errorf("zero %s written: %s", field, data)
+		}
+	}
+
+	data, err = json.Marshal(Notification{ID: "n1", RetryAt: time.Date(2026, time.October, 16, 9, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"retry_at":"2026-10-16T09:00:00Z"`) {
+		t.Errorf("retry_at not written: %s", data)
+	}
+}
@@ -0,0 +1,80 @@
+package pushover
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSendThroughProxy(t *testing.T) {
+	var mu sync.Mutex
+	var seen []*http.Request
+	// Answers in place of the API, as if it had forwarded the request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"proxied"}`))
+	}))
+	defer proxy.Close()
+
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://relay:s3cret@", 1)
+	httpClient, err := NewHTTPClient(proxyURL)
+	if err != nil {
+		t.Fatalf("NewHTTPClient: %v", err)
+	}
+	// The API host doesn't resolve: only the proxy can have answered
+	client := &Client{Token: "app-token", User: "user-key", BaseURL: "http://api.pushover.invalid/1", HTTPClient: httpClient}
+	if err := client.SendMessage("Reminder", "Through the proxy"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 1 {
+		t.Fatalf("proxy saw %d requests, want 1", len(seen))
+	}
+	r := seen[0]
+	if r.Method != "POST" || r.URL.Host != "api.pushover.invalid" || r.URL.Path != "/1/messages.json" {
+		t.Errorf("proxy got %s %s, want POST http://api.pushover.invalid/1/messages.json", r.Method, r.URL)
+	}
+	if got := r.PostForm.Get("message"); got != "Through the proxy" {
+		t.Errorf("message = %q", got)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("relay:s3cret"))
+	if got := r.Header.Get("Proxy-Authorization"); got != wantAuth {
+		t.Errorf("Proxy-Authorization = %q, want %q", got, wantAuth)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	if c, err := NewHTTPClient(""); c != nil || err != nil {
+		t.Errorf(`NewHTTPClient("") = %v, %v; want nil, nil`, c, err)
+	}
+	if c := (&Client{}).httpClient(); c.Timeout == 0 {
+		t.Error("default client has no timeout")
+	}
+	for _, proxy := range []string{"http://proxy:3128", "https://proxy", "socks5://proxy:1080"} {
+		c, err := NewHTTPClient(proxy)
+		if err != nil {
+			t.Errorf("NewHTTPClient(%q): %v", proxy, err)
+		} else if c.Timeout == 0 {
+			t.Errorf("NewHTTPClient(%q) has no timeout", proxy)
+		}
+	}
+	for _, proxy := range []string{"ftp://proxy", "proxy:3128", "http://", "http://user:hunter2@%zz"} {
+		_, err := NewHTTPClient(proxy)
+		if err == nil {
+			t.Errorf("NewHTTPClient(%q) accepted an invalid proxy", proxy)
+			continue
+		}
+		if strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("NewHTTPClient(%q) error shows the password: %v", proxy, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package pushover
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Send's errors match one of these with errors.Is when the cause is known, so callers
+// can decide whether a retry may help
+var (
+	// ErrInvalidCredentials means Pushover rejected the application token or user key
+	ErrInvalidCredentials = errors.New("invalid Pushover credentials")
+	// ErrRateLimited means the application has used up its message quota for now
+	ErrRateLimited = errors.New("Pushover rate limit reached")
+	// ErrTransient is a network failure or server error; the same request may succeed later
+	ErrTransient = errors.New("temporary Pushover failure")
+)
+
+// APIError is an error response from the messages API. Other rejected requests, such
+// as a message Pushover won't accept, match none of the sentinels.
+type APIError struct {
+	StatusCode int
+	Errors     []string // Pushover's explanation, e.g. "user identifier is not a valid user"
+	Request    string   // Pushover's ID for the request, to quote to their support
+	Body       string   // The raw response, when it isn't Pushover's JSON
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("pushover api error: status %d: %s", e.StatusCode, e.Message())
+	if e.Request != "" {
+		msg += " (request " + e.Request + ")"
+	}
+	return msg
+}
+
+// Message is what Pushover said was wrong, or the raw response when it didn't say
+func (e *APIError) Message() string {
+	if len(e.Errors) > 0 {
+		return strings.Join(e.Errors, "; ")
+	}
+	return e.Body
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// ErrorMessage is err's text for display: for a rejected request just Pushover's
+// explanation, without the status code and request ID
+func ErrorMessage(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && len(apiErr.Errors) > 0 {
+		return apiErr.Message()
+	}
+	return err.Error()
+}
+
+// maxErrorBody is how much of an unrecognised error response APIError keeps
+const maxErrorBody = 200
+
+// apiErrorResponse is the JSON body of a rejected request, e.g.
+// {"user": "invalid", "errors": ["user identifier is not a valid user"], "status": 0, "request": "..."}.
+// A rejected token or user key is flagged as "invalid" in its field.
+type apiErrorResponse struct {
+	Request string   `json:"request"`
+	Token   string   `json:"token"`
+	User    string   `json:"user"`
+	Errors  []string `json:"errors"`
+}
+
+// parseAPIError classifies an error response by its status code and body
+func parseAPIError(status int, body []byte) *APIError {
+	e := &APIError{StatusCode: status}
+	var resp apiErrorResponse
+	if err := json.Unmarshal(body, &resp); err == nil && len(resp.Errors) > 0 {
+		e.Errors, e.Request = resp.Errors, resp.Request
+	} else {
+		// Not Pushover's JSON, e.g. an HTML error page from a proxy: keep the start of it
+		e.Body = strings.TrimSpace(string(body))
+		if len(e.Body) > maxErrorBody {
+			e.Body = strings.ToValidUTF8(e.Body[:maxErrorBody], "") + "..."
+		}
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests:
+		e.kind = ErrRateLimited
+	case status >= 500:
+		e.kind = ErrTransient
+	case resp.Token == "invalid" || resp.User == "invalid" || status == http.StatusUnauthorized || status == http.StatusForbidden:
+		e.kind = ErrInvalidCredentials
+	}
+	return e
+}
@@ -0,0 +1,136 @@
+package pushover
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// allowedTag matches, at the start of its input, one of the tags Pushover renders
+// in HTML messages
+var allowedTag = regexp.MustCompile(`(?i)^(?:</?[biu]>|<font color="[^"<>]*">|</font>|<a href="[^"<>]*">|</a>)`)
+
+// anyTag matches, at the start of its input, anything shaped like an HTML tag. A '<'
+// not followed by a letter or '/', as in "buy <2> widgets", is text.
+var anyTag = regexp.MustCompile(`^</?[a-zA-Z][a-zA-Z0-9]*(?:\s[^<>]*)?/?>`)
+
+// entity matches, at the start of its input, a character reference like &amp; or &#39;
+var entity = regexp.MustCompile(`(?i)^&(?:[a-z][a-z0-9]*|#[0-9]+|#x[0-9a-f]+);`)
+
+// supportedTags lists the tags Pushover renders, for error messages
+const supportedTags = `<b>, <i>, <u>, <font color="...">, <a href="...">`
+
+// tagName returns the lowercase name of an allowed tag and whether it closes
+func tagName(tag string) (string, bool) {
+	closing := strings.HasPrefix(tag, "</")
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexAny(name, " >"); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name), closing
+}
+
+// ValidateHTML checks that the tags in a message are ones Pushover renders and that
+// they are balanced. Text like "a < b" or "<2>" is not taken for a tag.
+func ValidateHTML(s string) error {
+	var unsupported []string
+	var open []string // Names of tags not yet closed, innermost last
+	var unbalanced string
+	for i := 0; i < len(s); i++ {
+		if s[i] != '<' {
+			continue
+		}
+		tag := anyTag.FindString(s[i:])
+		if tag == "" {
+			continue
+		}
+		i += len(tag) - 1
+		if allowed := allowedTag.FindString(tag); allowed != tag {
+			if !slices.Contains(unsupported, tag) {
+				unsupported = append(unsupported, tag)
+			}
+			continue
+		}
+		if unbalanced != "" {
+			continue
+		}
+		name, closing := tagName(tag)
+		switch {
+		case !closing:
+			open = append(open, name)
+		case !slices.Contains(open, name):
+			unbalanced = fmt.Sprintf("%s has no opening tag", tag)
+		case open[len(open)-1] != name:
+			unbalanced = fmt.Sprintf("<%s> must be closed before %s", open[len(open)-1], tag)
+		default:
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("Unsupported HTML: %s. Pushover only shows %s", strings.Join(unsupported, ", "), supportedTags)
+	}
+	if unbalanced == "" && len(open) > 0 {
+		unbalanced = fmt.Sprintf("<%s> is never closed", open[len(open)-1])
+	}
+	if unbalanced != "" {
+		return fmt.Errorf("Unbalanced HTML: %s", unbalanced)
+	}
+	return nil
+}
+
+// escapeHTML prepares user text for a message sent with html=1. Tags Pushover
+// supports and character references are kept; any other '<', '>' or '&' is
+// escaped so it shows as typed, e.g. "buy <2> widgets". Messages saved before
+// ValidateHTML existed may have unbalanced tags: those left open are closed at the
+// end, and a closing tag with nothing to close is shown as typed.
+func escapeHTML(s string) string {
+	var b strings.Builder
+	var open []string // Names of kept tags not yet closed, innermost last
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '<':
+			if tag := allowedTag.FindString(s[i:]); tag != "" {
+				i += len(tag)
+				name, closing := tagName(tag)
+				if !closing {
+					open = append(open, name)
+					b.WriteString(tag)
+					continue
+				}
+				j := len(open) - 1 // Innermost open tag of the same name
+				for j >= 0 && open[j] != name {
+					j--
+				}
+				if j < 0 {
+					b.WriteString("&lt;" + tag[1:len(tag)-1] + "&gt;")
+					continue
+				}
+				// Close anything still open inside it first
+				for k := len(open) - 1; k >= j; k-- {
+					b.WriteString("</" + open[k] + ">")
+				}
+				open = open[:j]
+				continue
+			}
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		case '&':
+			if ref := entity.FindString(s[i:]); ref != "" {
+				b.WriteString(ref)
+				i += len(ref)
+				continue
+			}
+			b.WriteString("&amp;")
+		default:
+			b.WriteByte(s[i])
+		}
+		i++
+	}
+	for k := len(open) - 1; k >= 0; k-- {
+		b.WriteString("</" + open[k] + ">")
+	}
+	return b.String()
+}
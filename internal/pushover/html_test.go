@@ -0,0 +1,72 @@
+package pushover
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		wantErr string // Substring of the error; empty when valid
+	}{
+		{"plain text", "Call the dentist", ""},
+		{"bold, italic, underline", "<b>now</b>, <i>please</i>, <u>really</u>", ""},
+		{"upper case tags", "<B>now</B>", ""},
+		{"font color", `<font color="#ff0000">red</font>`, ""},
+		{"link", `<a href="https://example.com">site</a>`, ""},
+		{"nested", "<b><i>both</i></b>", ""},
+		{"less than in text", "a < b and b > c", ""},
+		{"number in brackets", "buy <2> widgets", ""},
+		{"ampersand", "salt & pepper", ""},
+		{"script", "<script>alert(1)</script>", "Unsupported HTML: <script>, </script>"},
+		{"image", `<img src="x.png">`, "Unsupported HTML"},
+		{"link without href", "<a>site</a>", "Unsupported HTML: <a>"},
+		{"font without color", `<font size="3">big</font>`, "Unsupported HTML"},
+		{"never closed", "<b>bold", "<b> is never closed"},
+		{"closed without opening", "bold</b>", "</b> has no opening tag"},
+		{"crossed", "<b><i>x</b></i>", "<i> must be closed before </b>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateHTML(tt.message)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateHTML(%q) = %v, want nil", tt.message, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateHTML(%q) = %v, want an error containing %q", tt.message, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"<b>kept</b>", "<b>kept</b>"},
+		{`<font color="blue">kept</font>`, `<font color="blue">kept</font>`},
+		{`<a href="https://example.com/?a=1">kept</a>`, `<a href="https://example.com/?a=1">kept</a>`},
+		{"a < b > c", "a &lt; b &gt; c"},
+		{"buy <2> widgets", "buy &lt;2&gt; widgets"},
+		{"salt & pepper", "salt &amp; pepper"},
+		{"&amp; &#39; &#x2764; kept", "&amp; &#39; &#x2764; kept"},
+		{"&nosemicolon", "&amp;nosemicolon"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{`<img src="x">`, `&lt;img src="x"&gt;`},
+		// Left by messages saved before ValidateHTML existed
+		{"<b>open", "<b>open</b>"},
+		{"stray</b>", "stray&lt;/b&gt;"},
+		{"<b><i>x</b>", "<b><i>x</i></b>"},
+	}
+	for _, tt := range tests {
+		if got := escapeHTML(tt.in); got != tt.want {
+			t.Errorf("escapeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
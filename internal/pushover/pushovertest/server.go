@@ -0,0 +1,87 @@
+// Package pushovertest provides a fake Pushover messages API for tests
+package pushovertest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
+)
+
+// Server emulates POST /1/messages.json, recording each request and replying
+// with a configurable status and body (by default a success response)
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []url.Values
+	status    int
+	body      string
+	onRequest func()
+}
+
+func NewServer() *Server {
+	s := &Server{
+		status: http.StatusOK,
+		body:   `{"status":1,"request":"pushovertest"}`,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handleMessages))
+	return s
+}
+
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/1/messages.json" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.PostForm)
+	status, body, onRequest := s.status, s.body, s.onRequest
+	s.mu.Unlock()
+	if onRequest != nil {
+		onRequest()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+// SetResponse changes the reply to subsequent requests, e.g. to simulate API errors
+func (s *Server) SetResponse(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.body = status, body
+}
+
+// SetOnRequest runs fn on each request before it is answered, e.g. to change the
+// store while a send is in flight
+func (s *Server) SetOnRequest(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRequest = fn
+}
+
+// Requests returns the form fields of every message received so far
+func (s *Server) Requests() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.requests...)
+}
+
+// BaseURL is the value to use as pushover.Client.BaseURL
+func (s *Server) BaseURL() string {
+	return s.URL + "/1"
+}
+
+// Client returns a client that talks to this server
+func (s *Server) Client(token, user string) *pushover.Client {
+	return &pushover.Client{Token: token, User: user, BaseURL: s.BaseURL()}
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// AuditLog is an append-only JSON Lines file of audit events.
+// It has its own lock so audit writes never contend with the main store lock.
+// With an empty path events are kept in memory instead. A path with date tokens
+// rotates; see pathFor.
+type AuditLog struct {
+	mu       sync.Mutex
+	filePath string
+	events   []model.AuditEvent // In-memory mode only
+}
+
+// Cap for in-memory audit events so an ephemeral store can't grow without bound
+const maxMemoryAuditEvents = 10000
+
+func NewAuditLog(filePath string) *AuditLog {
+	return &AuditLog{filePath: filePath}
+}
+
+func (a *AuditLog) Append(event model.AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.filePath == "" {
+		a.events = append(a.events, event)
+		if len(a.events) > maxMemoryAuditEvents {
+			a.events = a.events[len(a.events)-maxMemoryAuditEvents:]
+		}
+		return nil
+	}
+
+	path := a.pathFor(event.Time)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Recent returns up to limit events, newest first. Files are read without holding the
+// lock, so a long read doesn't hold up appends; an event being appended meanwhile is
+// either read whole or skipped.
+func (a *AuditLog) Recent(limit int) ([]model.AuditEvent, error) {
+	a.mu.Lock()
+	if a.filePath == "" {
+		events := make([]model.AuditEvent, len(a.events))
+		copy(events, a.events)
+		a.mu.Unlock()
+		return newestFirst(events, limit), nil
+	}
+	paths, err := a.files()
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest file first, stopping once there are enough events
+	var events []model.AuditEvent
+	for _, path := range paths {
+		fileEvents, err := readAuditFile(path)
+		if err != nil {
+			return nil, err
+		}
+		events = append(fileEvents, events...)
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+	}
+	return newestFirst(events, limit), nil
+}
+
+// newestFirst reverses events, oldest first, and keeps the first limit of them
+func newestFirst(events []model.AuditEvent, limit int) []model.AuditEvent {
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// Date tokens an audit file path may contain, e.g. "data/audit-{year}-{month}.jsonl"
+const (
+	tokenYear  = "{year}"
+	tokenMonth = "{month}"
+	tokenDay   = "{day}"
+)
+
+// pathFor returns the file an event at t is written to: the path with its date tokens
+// replaced by t's local date. Without tokens that is always the same file; with them
+// the log starts a new file each month (or day, or year), so no single file grows
+// without end.
+func (a *AuditLog) pathFor(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.Local()
+	return strings.NewReplacer(
+		tokenYear, t.Format("2006"),
+		tokenMonth, t.Format("01"),
+		tokenDay, t.Format("02"),
+	).Replace(a.filePath)
+}
+
+// files returns the existing log files, newest first. Files are ordered by the date in
+// their name, whatever order the tokens appear in. Caller must hold mu.
+func (a *AuditLog) files() ([]string, error) {
+	if !strings.Contains(a.filePath, "{") {
+		return []string{a.filePath}, nil
+	}
+
+	// Match "{year}" and friends as wildcards, then read the date back out of each name
+	var glob, pattern strings.Builder
+	var order []string // Tokens in the order their groups appear in pattern
+	rest := a.filePath
+	for rest != "" {
+		i := strings.IndexByte(rest, '{')
+		token := ""
+		if i >= 0 {
+			for _, t := range []string{tokenYear, tokenMonth, tokenDay} {
+				if strings.HasPrefix(rest[i:], t) {
+					token = t
+				}
+			}
+		}
+		if token == "" {
+			// No token here: take up to and including the brace literally
+			n := len(rest)
+			if i >= 0 {
+				n = i + 1
+			}
+			glob.WriteString(escapeGlob(rest[:n]))
+			pattern.WriteString(regexp.QuoteMeta(rest[:n]))
+			rest = rest[n:]
+			continue
+		}
+		glob.WriteString(escapeGlob(rest[:i]) + "*")
+		pattern.WriteString(regexp.QuoteMeta(rest[:i]))
+		if token == tokenYear {
+			pattern.WriteString(`(\d{4})`)
+		} else {
+			pattern.WriteString(`(\d{2})`)
+		}
+		order = append(order, token)
+		rest = rest[i+len(token):]
+	}
+
+	matches, err := filepath.Glob(glob.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	re := regexp.MustCompile("^" + pattern.String() + "$")
+	type dated struct{ path, date string }
+	var found []dated
+	for _, path := range matches {
+		m := re.FindStringSubmatch(path)
+		if m == nil {
+			continue // Some other file that happens to match the wildcards
+		}
+		parts := map[string]string{}
+		for i, token := range order {
+			parts[token] = m[i+1]
+		}
+		found = append(found, dated{path, parts[tokenYear] + parts[tokenMonth] + parts[tokenDay]})
+	}
+	slices.SortFunc(found, func(x, y dated) int { return strings.Compare(y.date, x.date) })
+
+	paths := make([]string, len(found))
+	for i, d := range found {
+		paths[i] = d.path
+	}
+	return paths, nil
+}
+
+// escapeGlob quotes the characters filepath.Glob treats specially
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(s)
+}
+
+// maxAuditLineSize bounds one event in a log file. Events quote notification content,
+// which can exceed bufio.Scanner's 64 KB default; request bodies are capped well below this.
+const maxAuditLineSize = 1 << 20
+
+// readAuditFile reads every event from a log file; a missing file has none
+func readAuditFile(path string) ([]model.AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []model.AuditEvent{}, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var events []model.AuditEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAuditLineSize)
+	for scanner.Scan() {
+		var e model.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // Skip corrupt lines rather than hiding the whole log
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+func TestAuditLogRotation(t *testing.T) {
+	saved := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = saved })
+
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+	// Across the end of January, and of a leap-year February
+	events := []model.AuditEvent{
+		{Time: at(2028, time.January, 31, 23, 59), Detail: "jan-31"},
+		{Time: at(2028, time.February, 1, 0, 0), Detail: "feb-1"},
+		{Time: at(2028, time.February, 28, 23, 59), Detail: "feb-28"},
+		{Time: at(2028, time.February, 29, 12, 0), Detail: "feb-29"},
+		{Time: at(2028, time.March, 1, 0, 0), Detail: "mar-1"},
+	}
+	tests := []struct {
+		name  string
+		path  string
+		files map[string]int // Events written to each file
+	}{
+		{"monthly", "audit-{year}-{month}.jsonl", map[string]int{
+			"audit-2028-01.jsonl": 1, "audit-2028-02.jsonl": 3, "audit-2028-03.jsonl": 1,
+		}},
+		{"daily", "{year}/{month}/audit-{day}.jsonl", map[string]int{
+			"2028/01/audit-31.jsonl": 1, "2028/02/audit-01.jsonl": 1, "2028/02/audit-28.jsonl": 1,
+			"2028/02/audit-29.jsonl": 1, "2028/03/audit-01.jsonl": 1,
+		}},
+		{"day before month", "audit-{day}.{month}.{year}.jsonl", map[string]int{
+			"audit-31.01.2028.jsonl": 1, "audit-01.02.2028.jsonl": 1, "audit-28.02.2028.jsonl": 1,
+			"audit-29.02.2028.jsonl": 1, "audit-01.03.2028.jsonl": 1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a := NewAuditLog(filepath.Join(dir, tt.path))
+			for _, e := range events {
+				if err := a.Append(e); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+
+			for name, want := range tt.files {
+				got, err := readAuditFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("reading %s: %v", name, err)
+				}
+				if len(got) != want {
+					t.Errorf("%s has %d events, want %d", name, len(got), want)
+				}
+			}
+
+			all, err := a.Recent(0)
+			if err != nil {
+				t.Fatalf("Recent: %v", err)
+			}
+			var order []string
+			for _, e := range all {
+				order = append(order, e.Detail)
+			}
+			if want := []string{"mar-1", "feb-29", "feb-28", "feb-1", "jan-31"}; !slices.Equal(order, want) {
+				t.Errorf("Recent(0) = %q, want %q", order, want)
+			}
+			latest, err := a.Recent(2)
+			if err != nil {
+				t.Fatalf("Recent: %v", err)
+			}
+			if len(latest) != 2 || latest[0].Detail != "mar-1" || latest[1].Detail != "feb-29" {
+				t.Errorf("Recent(2) = %v, want mar-1, feb-29", latest)
+			}
+		})
+	}
+}
+
+func TestAuditLogPathFor(t *testing.T) {
+	saved := time.Local
+	time.Local = time.FixedZone("UTC+1", 60*60)
+	t.Cleanup(func() { time.Local = saved })
+
+	a := NewAuditLog("audit-{year}-{month}-{day}.jsonl")
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		// Files follow the server's local date, not UTC's
+		{time.Date(2027, time.December, 31, 23, 30, 0, 0, time.UTC), "audit-2028-01-01.jsonl"},
+		{time.Date(2028, time.February, 28, 23, 0, 0, 0, time.UTC), "audit-2028-02-29.jsonl"},
+		{time.Date(2027, time.February, 28, 23, 0, 0, 0, time.UTC), "audit-2027-03-01.jsonl"},
+		{time.Date(2028, time.January, 31, 22, 59, 0, 0, time.UTC), "audit-2028-01-31.jsonl"},
+	}
+	for _, tt := range tests {
+		if got := a.pathFor(tt.at); got != tt.want {
+			t.Errorf("pathFor(%s) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestAuditLogLongEntry(t *testing.T) {
+	a := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	long := strings.Repeat("x", 200*1024) // Past bufio.Scanner's default 64 KB line limit
+	for _, detail := range []string{"before", long, "after"} {
+		if err := a.Append(model.AuditEvent{Time: time.Now(), Detail: detail}); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	events, err := a.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(events) != 3 || events[0].Detail != "after" || events[1].Detail != long || events[2].Detail != "before" {
+		t.Errorf("Recent returned %d events, want all 3 with the long one intact", len(events))
+	}
+}
+
+func TestAuditLogConcurrentReads(t *testing.T) {
+	a := NewAuditLog(filepath.Join(t.TempDir(), "audit-{year}-{month}.jsonl"))
+	const writers, perWriter = 4, 50
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				if err := a.Append(model.AuditEvent{Time: time.Now(), Detail: fmt.Sprintf("%d-%d", w, i)}); err != nil {
+					t.Errorf("Append: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	// Reads alongside the writes only ever see whole events
+	stop := make(chan struct{})
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			events, err := a.Recent(0)
+			if err != nil {
+				t.Errorf("Recent: %v", err)
+				return
+			}
+			for _, e := range events {
+				if e.Detail == "" {
+					t.Error("Recent returned a partly written event")
+					return
+				}
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-readDone
+
+	events, err := a.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(events) != writers*perWriter {
+		t.Errorf("Recent returned %d events, want %d", len(events), writers*perWriter)
+	}
+}
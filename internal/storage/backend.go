@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// Backend is the storage used by the web server and worker.
+// Store persists to a JSON file; InMemoryStore keeps everything in memory.
+type Backend interface {
+	Load() error
+	Save() error
+	Version() uint64
+	Health() Health
+	SetMaxPending(n int)
+	SetCompact(compact bool)
+
+	GetSettings() model.Settings
+	UpdateSettings(settings model.Settings) error
+
+	GetAllNotifications() []*model.Notification
+	GetPending() []*model.Notification
+	GetNotification(id string) (*model.Notification, error)
+	GetGroup(groupID string) []*model.Notification
+	AddNotification(n *model.Notification, actor string) error
+	AddNotifications(ns []*model.Notification, actor string) error
+	UpdateNotification(updated *model.Notification, actor string) error
+	DeleteNotification(id string, actor string) error
+	AcknowledgeNotification(token string) (*model.Notification, error)
+	AcknowledgeReceipt(receipt string, at time.Time) (*model.Notification, error)
+	AcknowledgeReply(token string) (*model.Notification, error)
+	WithTransaction(fn func(tx *Tx) error) error
+
+	Validate() []error
+	Repair(actor string) (RepairResult, error)
+
+	SendStats(now time.Time, match func(*model.Notification) bool, windows ...time.Duration) []SendCounts
+
+	AppendAudit(event model.AuditEvent)
+	GetAuditLog(limit int) ([]model.AuditEvent, error)
+}
+
+var (
+	_ Backend = (*Store)(nil)
+	_ Backend = (*InMemoryStore)(nil)
+)
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// gzipExt ends the name of a data file stored gzip-compressed
+const gzipExt = ".gz"
+
+// compressed reports whether the data file is written gzip-compressed
+func (s *Store) compressed() bool {
+	return strings.HasSuffix(s.filePath, gzipExt)
+}
+
+// readDataFile reads the data file, decompressing it if it holds gzip data whatever
+// its name. A missing .gz file falls back to the plain file of the same name without
+// the extension, so pointing file_path at data.json.gz keeps the data in data.json;
+// the next write creates the compressed file.
+func (s *Store) readDataFile() ([]byte, error) {
+	data, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) && s.compressed() {
+		plainPath := strings.TrimSuffix(s.filePath, gzipExt)
+		if plain, plainErr := os.ReadFile(plainPath); plainErr == nil {
+			slog.Info("Loading uncompressed data file; it is written compressed from the next change", "file", plainPath)
+			data, err = plain, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decompress(data)
+}
+
+// decompress returns data unzipped when it starts with the gzip header, and as it is
+// otherwise
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer zr.Close()
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+	return plain, nil
+}
+
+// compress gzips data
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -2,10 +2,16 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/noahxzhu/pushover-notify/internal/model"
@@ -16,11 +22,72 @@ type Store struct {
 	filePath       string
 	Data           *model.AppSchema
 	lastLoadedTime time.Time
+	audit          *AuditLog
+	memory         bool          // In-memory mode: Load and Save don't touch disk
+	version        atomic.Uint64 // Bumped on every change, for cheap staleness checks
+	maxPending     int           // Cap on notifications not yet Done; see WithTransaction
+	compact        bool          // Write the data file without indentation
+
+	// Write health, guarded by mu; see persistLocked
+	writeErr      error
+	writeFailures int
+	failingSince  time.Time
+	unsaved       bool
 }
 
-func NewStore(filePath string) *Store {
+// DefaultMaxPending caps pending notifications unless configured otherwise. It is far
+// above any hand-made list and only there to stop runaway automation.
+const DefaultMaxPending = 10000
+
+// ErrTooManyPending is returned when adding notifications would exceed the pending cap
+var ErrTooManyPending = errors.New("too many pending notifications")
+
+// ErrNotFound is returned for a notification ID that isn't in the store
+var ErrNotFound = errors.New("notification not found")
+
+// ErrIDExists is returned when adding a notification whose ID is already taken, e.g.
+// by imported or hand-edited data; lookups by ID would otherwise find only one of them
+var ErrIDExists = errors.New("notification ID already exists")
+
+// DuplicateError is returned when adding a duplicate of a pending notification
+// while DedupeMode is DedupeReject
+type DuplicateError struct {
+	ExistingID string
+}
+
+func (e *DuplicateError) Error() string {
+	return "duplicate of pending notification " + e.ExistingID
+}
+
+// Consecutive write failures after which the store stops failing requests and
+// keeps changes in memory instead
+const degradeAfterFailures = 3
+
+// Health describes whether the store's changes are reaching disk
+type Health struct {
+	// Unsaved is set once writes are failing persistently: changes are kept in
+	// memory, but will be lost on restart until a write succeeds again
+	Unsaved      bool
+	Err          error
+	FailingSince time.Time
+}
+
+func defaultSchema() *model.AppSchema {
+	return &model.AppSchema{
+		Settings:      model.Settings{TotalSends: 3, RepeatInterval: "30m", DefaultTitle: "Reminder"},
+		Notifications: []*model.Notification{},
+	}
+}
+
+// NewStore creates a store backed by filePath. If auditFilePath is empty the
+// audit log is kept next to the data file as audit.jsonl.
+func NewStore(filePath, auditFilePath string) *Store {
+	if auditFilePath == "" {
+		auditFilePath = filepath.Join(filepath.Dir(filePath), "audit.jsonl")
+	}
 	return &Store{
 		filePath: filePath,
+		audit:    NewAuditLog(auditFilePath),
 		Data: &model.AppSchema{
 			Settings:      model.Settings{},
 			Notifications: []*model.Notification{},
@@ -28,7 +95,43 @@ func NewStore(filePath string) *Store {
 	}
 }
 
+// SetMaxPending caps the number of notifications that aren't Done; 0 uses DefaultMaxPending
+func (s *Store) SetMaxPending(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPending = n
+}
+
+// SetCompact makes writes leave out the indentation, for a smaller data file that is
+// harder to edit by hand. It applies from the next write; Load reads either form.
+func (s *Store) SetCompact(compact bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compact = compact
+}
+
+// pendingLimitLocked returns the pending cap; the caller must hold s.mu
+func (s *Store) pendingLimitLocked() int {
+	if s.maxPending > 0 {
+		return s.maxPending
+	}
+	return DefaultMaxPending
+}
+
+// Version returns a counter that changes whenever the data changes. Each mutation
+// (add, update, delete, UpdateSettings, acknowledgement, Repair) advances it exactly
+// once, as does a reload from disk, so "changed since version X" is one comparison.
+// A transaction that is refused before saving leaves it as it was.
+func (s *Store) Version() uint64 {
+	return s.version.Load()
+}
+
 func (s *Store) Load() error {
+	if s.memory {
+		return nil
+	}
+	defer s.version.Add(1)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -37,86 +140,182 @@ func (s *Store) Load() error {
 		s.lastLoadedTime = info.ModTime()
 	}
 
-	data, err := os.ReadFile(s.filePath)
+	data, err := s.readDataFile()
 	if err != nil {
 		if os.IsNotExist(err) {
-			s.Data = &model.AppSchema{
-				Settings:      model.Settings{RepeatTimes: 3, RepeatInterval: "30m"},
-				Notifications: []*model.Notification{},
-			}
+			s.Data = defaultSchema()
 			return nil
 		}
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
 	if len(data) == 0 {
-		s.Data = &model.AppSchema{
-			Settings:      model.Settings{RepeatTimes: 3, RepeatInterval: "30m"},
-			Notifications: []*model.Notification{},
-		}
+		s.Data = defaultSchema()
 		return nil
 	}
 
-	if err := json.Unmarshal(data, &s.Data); err != nil {
-		// Attempt migration from old []Notification format
+	loaded, err := decodeData(data)
+	if err != nil {
+		return err
+	}
+	s.Data = loaded
+	return nil
+}
+
+// decodeData decodes a data file into a new schema, migrating old formats and filling
+// in defaults. It never decodes into the store's current data: readers may still hold
+// its notifications, and fields missing from the file must not keep their old values.
+func decodeData(data []byte) (*model.AppSchema, error) {
+	loaded := &model.AppSchema{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		// Attempt migration from old []Notification format, which then gets the same
+		// migrations and defaults
 		var oldNotifs []*model.Notification
-		if err2 := json.Unmarshal(data, &oldNotifs); err2 == nil {
-			s.Data = &model.AppSchema{
-				Settings:      model.Settings{RepeatTimes: 3, RepeatInterval: "30m"},
-				Notifications: oldNotifs,
-			}
-			return nil
+		if err2 := json.Unmarshal(data, &oldNotifs); err2 != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
 		}
-
-		return fmt.Errorf("failed to unmarshal data: %w", err)
+		loaded = defaultSchema()
+		loaded.Notifications = oldNotifs
 	}
+	migrateRepeatTimes(data, loaded)
 
 	// Set defaults
-	if s.Data.Settings.RepeatTimes == 0 {
-		s.Data.Settings.RepeatTimes = 3
+	if loaded.Settings.TotalSends == 0 {
+		loaded.Settings.TotalSends = 3
+	}
+	if loaded.Settings.RepeatInterval == "" {
+		loaded.Settings.RepeatInterval = "30m"
 	}
-	if s.Data.Settings.RepeatInterval == "" {
-		s.Data.Settings.RepeatInterval = "30m"
+	if loaded.Settings.DefaultTitle == "" {
+		loaded.Settings.DefaultTitle = "Reminder"
 	}
-	if s.Data.Notifications == nil {
-		s.Data.Notifications = []*model.Notification{}
+	if loaded.Notifications == nil {
+		loaded.Notifications = []*model.Notification{}
 	}
 
 	// Migration/Defaults for legacy data
-	// If RepeatTimes is 0 or RepeatInterval is empty, assume legacy and use current settings (or defaults)
+	// If TotalSends is 0 or RepeatInterval is empty, assume legacy and use current settings (or defaults)
 	// Note: This treats intentional "0 retries" as "use default" for existing data, which is acceptable for migration.
 	// For new data, we will likely enforce > 0 or handle logic in worker.
-	globalRepeatTimes := s.Data.Settings.RepeatTimes
-	globalRepeatInterval := s.Data.Settings.RepeatInterval
+	globalTotalSends := loaded.Settings.TotalSends
+	globalRepeatInterval := loaded.Settings.RepeatInterval
 
 	// Ensure Global defaults if they were somehow 0/empty
-	if globalRepeatTimes == 0 {
-		globalRepeatTimes = 3
+	if globalTotalSends == 0 {
+		globalTotalSends = 3
 	}
 	if globalRepeatInterval == "" {
 		globalRepeatInterval = "30m"
 	}
 
-	for _, n := range s.Data.Notifications {
-		if n.RepeatTimes == 0 {
-			n.RepeatTimes = globalRepeatTimes
+	for _, n := range loaded.Notifications {
+		if n.TotalSends == 0 {
+			n.TotalSends = globalTotalSends
 		}
 		if n.RepeatInterval == "" {
 			n.RepeatInterval = globalRepeatInterval
 		}
 	}
 
-	return nil
+	return loaded, nil
+}
+
+// migrateRepeatTimes carries over counts saved as "repeat_times", the old name of
+// "total_sends". The meaning is unchanged: the total number of sends, first included.
+// raw may also be the old bare array of notifications.
+func migrateRepeatTimes(raw []byte, data *model.AppSchema) {
+	type legacyNotification struct {
+		RepeatTimes int `json:"repeat_times"`
+	}
+	var legacy struct {
+		Settings struct {
+			RepeatTimes int `json:"repeat_times"`
+		} `json:"settings"`
+		Notifications []legacyNotification `json:"notifications"`
+	}
+	if json.Unmarshal(raw, &legacy) != nil && json.Unmarshal(raw, &legacy.Notifications) != nil {
+		return
+	}
+	if data.Settings.TotalSends == 0 {
+		data.Settings.TotalSends = legacy.Settings.RepeatTimes
+	}
+	for i, n := range data.Notifications {
+		if i < len(legacy.Notifications) && n.TotalSends == 0 {
+			n.TotalSends = legacy.Notifications[i].RepeatTimes
+		}
+	}
 }
 
 func (s *Store) Save() error {
+	// Every mutation ends in Save, so this is where changes are counted
+	s.version.Add(1)
+	if s.memory {
+		return nil
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.persistLocked()
+}
+
+// persistLocked writes the data to disk and tracks failures. A permission or
+// read-only error, or degradeAfterFailures failures in a row, puts the store in
+// degraded mode: writes report success so changes are kept in memory, and the
+// problem is surfaced through Health until a write gets through. The caller must hold s.mu.
+func (s *Store) persistLocked() error {
+	err := s.writeLocked()
+	if err == nil {
+		if s.unsaved {
+			slog.Info("Storage is writable again, unsaved changes written", "file", s.filePath)
+		}
+		s.writeErr, s.writeFailures, s.failingSince, s.unsaved = nil, 0, time.Time{}, false
+		return nil
+	}
+
+	if s.writeFailures == 0 {
+		s.failingSince = time.Now()
+	}
+	s.writeFailures++
+	s.writeErr = err
+	if !s.unsaved && (isNotWritable(err) || s.writeFailures >= degradeAfterFailures) {
+		slog.Error("Storage is not writable, keeping changes in memory", "file", s.filePath, "error", err)
+		s.unsaved = true
+	}
+	if s.unsaved {
+		return nil
+	}
+	return err
+}
 
-	data, err := json.MarshalIndent(s.Data, "", "  ")
+// isNotWritable reports errors that won't go away by retrying
+func isNotWritable(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS)
+}
+
+// Health reports the outcome of recent writes
+func (s *Store) Health() Health {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Health{Unsaved: s.unsaved, Err: s.writeErr, FailingSince: s.failingSince}
+}
+
+// writeLocked writes the data to disk; the caller must hold s.mu
+func (s *Store) writeLocked() error {
+	var data []byte
+	var err error
+	if s.compact {
+		data, err = json.Marshal(s.Data)
+	} else {
+		data, err = json.MarshalIndent(s.Data, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
+	if s.compressed() {
+		if data, err = compress(data); err != nil {
+			return fmt.Errorf("failed to compress data: %w", err)
+		}
+	}
 
 	// Ensure directory exists
 	dir := filepath.Dir(s.filePath)
@@ -124,7 +323,7 @@ func (s *Store) Save() error {
 		return fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
+	if err := writeFileAtomic(s.filePath, data); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
@@ -136,14 +335,55 @@ func (s *Store) Save() error {
 	return nil
 }
 
+// writeFileAtomic writes data to path+".tmp", syncs it and renames it over path, so a
+// crash or a full disk mid-write leaves the previous file whole rather than cut short;
+// a truncated gzip file can't be read at all. An existing file's permissions are kept.
+func writeFileAtomic(path string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp) // Left over from a write cut short, with permissions of its own
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Sync the directory too, so the rename itself survives a crash
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
 func (s *Store) CheckDiskChanges() {
+	if s.memory {
+		return
+	}
+
 	info, err := os.Stat(s.filePath)
 	if err != nil {
 		return
 	}
 
 	s.mu.RLock()
-	needsReload := info.ModTime().After(s.lastLoadedTime)
+	// Reloading while degraded would throw away the changes that only exist in memory
+	needsReload := info.ModTime().After(s.lastLoadedTime) && !s.unsaved
 	s.mu.RUnlock()
 
 	if needsReload {
@@ -152,11 +392,39 @@ func (s *Store) CheckDiskChanges() {
 	}
 }
 
-func (s *Store) AddNotification(n *model.Notification) error {
-	s.mu.Lock()
-	s.Data.Notifications = append(s.Data.Notifications, n)
-	s.mu.Unlock()
-	return s.Save()
+// AppendAudit records an event in the audit log. Failures are logged, not returned,
+// so auditing never breaks the mutation that triggered it.
+func (s *Store) AppendAudit(event model.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if err := s.audit.Append(event); err != nil {
+		slog.Error("Failed to append audit event", "error", err, "action", event.Action)
+	}
+}
+
+// GetAuditLog returns up to limit audit events, newest first
+func (s *Store) GetAuditLog(limit int) ([]model.AuditEvent, error) {
+	return s.audit.Recent(limit)
+}
+
+func (s *Store) AddNotification(n *model.Notification, actor string) error {
+	return s.WithTransaction(func(tx *Tx) error {
+		return tx.AddNotification(n, actor)
+	})
+}
+
+// AddNotifications adds several notifications with a single save, so either all are
+// persisted or, if the save fails, none are
+func (s *Store) AddNotifications(ns []*model.Notification, actor string) error {
+	return s.WithTransaction(func(tx *Tx) error {
+		for _, n := range ns {
+			if err := tx.AddNotification(n, actor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (s *Store) UpdateSettings(settings model.Settings) error {
@@ -173,6 +441,9 @@ func (s *Store) GetSettings() model.Settings {
 	return s.Data.Settings
 }
 
+// GetAllNotifications returns every notification. Like every notification the store
+// hands out, they are shared and must not be modified: changes go through
+// WithTransaction as modified copies, which replace them.
 func (s *Store) GetAllNotifications() []*model.Notification {
 	s.CheckDiskChanges()
 	s.mu.RLock()
@@ -183,6 +454,9 @@ func (s *Store) GetAllNotifications() []*model.Notification {
 	return result
 }
 
+// GetPending returns the notifications that aren't Done, as they stand now. Changes
+// replace notifications rather than modify them, so the result is a snapshot that
+// stays consistent however long it is kept.
 func (s *Store) GetPending() []*model.Notification {
 	s.CheckDiskChanges()
 	s.mu.RLock()
@@ -197,53 +471,92 @@ func (s *Store) GetPending() []*model.Notification {
 	return pending
 }
 
+// GetGroup returns the members of a notification group, in store order
+func (s *Store) GetGroup(groupID string) []*model.Notification {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var members []*model.Notification
+	for _, n := range s.Data.Notifications {
+		if groupID != "" && n.GroupID == groupID {
+			members = append(members, n)
+		}
+	}
+	return members
+}
+
 func (s *Store) GetNotification(id string) (*model.Notification, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	for _, n := range s.Data.Notifications {
 		if n.ID == id {
-			// Return copy? Or pointer? Pointer is risky if modified outside lock, but currently we modify in handlers heavily.
-			// Safe approach: return the pointer but modifications should ideally be via Update.
-			// However, our UpdateNotification implementation will replace values.
 			return n, nil
 		}
 	}
-	return nil, fmt.Errorf("notification not found")
+	return nil, ErrNotFound
 }
 
-func (s *Store) UpdateNotification(updated *model.Notification) error {
-	s.mu.Lock()
-	found := false
-	for i, n := range s.Data.Notifications {
-		if n.ID == updated.ID {
-			s.Data.Notifications[i] = updated
-			found = true
-			break
-		}
+func (s *Store) UpdateNotification(updated *model.Notification, actor string) error {
+	return s.WithTransaction(func(tx *Tx) error {
+		return tx.UpdateNotification(updated, actor)
+	})
+}
+
+// AcknowledgeNotification marks the notification owning token as Done so the worker stops repeating it
+func (s *Store) AcknowledgeNotification(token string) (*model.Notification, error) {
+	if token == "" {
+		return nil, ErrNotFound
 	}
-	s.mu.Unlock()
+	return s.acknowledge(func(n *model.Notification) bool { return n.AckToken == token }, time.Now(), "ack-link")
+}
 
-	if !found {
-		return fmt.Errorf("notification not found")
+// AcknowledgeReceipt marks the notification that was sent with an emergency receipt
+// as acknowledged at the given time, as AcknowledgeNotification does for a token
+func (s *Store) AcknowledgeReceipt(receipt string, at time.Time) (*model.Notification, error) {
+	if receipt == "" {
+		return nil, ErrNotFound
 	}
-	return s.Save()
+	return s.acknowledge(func(n *model.Notification) bool { return slices.Contains(n.Receipts, receipt) }, at, "pushover")
+}
+
+// AcknowledgeReply marks the notification whose messages quote a reply token as
+// Done, as AcknowledgeNotification does for an ack token
+func (s *Store) AcknowledgeReply(token string) (*model.Notification, error) {
+	if token == "" {
+		return nil, ErrNotFound
+	}
+	return s.acknowledge(func(n *model.Notification) bool { return n.ReplyToken == token }, time.Now(), "reply")
 }
 
-func (s *Store) DeleteNotification(id string) error {
+// acknowledge marks the first notification matching match as Done. A notification
+// already acknowledged is returned as it is.
+func (s *Store) acknowledge(match func(*model.Notification) bool, at time.Time, actor string) (*model.Notification, error) {
 	s.mu.Lock()
-	found := false
-	for i, n := range s.Data.Notifications {
-		if n.ID == id {
-			s.Data.Notifications = append(s.Data.Notifications[:i], s.Data.Notifications[i+1:]...)
-			found = true
-			break
-		}
+	i := slices.IndexFunc(s.Data.Notifications, match)
+	if i < 0 {
+		s.mu.Unlock()
+		return nil, ErrNotFound
 	}
+	if acked := s.Data.Notifications[i]; !acked.AcknowledgedAt.IsZero() {
+		s.mu.Unlock()
+		return acked, nil
+	}
+	acked := *s.Data.Notifications[i]
+	acked.Status = model.StatusDone
+	acked.AcknowledgedAt = at
+	s.Data.Notifications[i] = &acked
 	s.mu.Unlock()
 
-	if !found {
-		return fmt.Errorf("notification not found")
+	if err := s.Save(); err != nil {
+		return nil, err
 	}
-	return s.Save()
+	s.AppendAudit(model.AuditEvent{Action: model.AuditAck, NotificationID: acked.ID, Content: acked.Content, Actor: actor})
+	return &acked, nil
+}
+
+func (s *Store) DeleteNotification(id string, actor string) error {
+	return s.WithTransaction(func(tx *Tx) error {
+		return tx.DeleteNotification(id, actor)
+	})
 }
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+func TestVersionBumpsOncePerMutation(t *testing.T) {
+	stores := map[string]func(t *testing.T) Backend{
+		"json": func(t *testing.T) Backend {
+			dir := t.TempDir()
+			s := NewStore(filepath.Join(dir, "data.json"), filepath.Join(dir, "audit.log"))
+			if err := s.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			return s
+		},
+		"memory": func(t *testing.T) Backend { return NewInMemoryStore() },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			n := &model.Notification{ID: "n1", Content: "first", Status: model.StatusPending, ScheduledTime: time.Now().Add(time.Hour)}
+			if err := s.AddNotification(n, "test"); err != nil {
+				t.Fatalf("AddNotification: %v", err)
+			}
+
+			// mutate runs change and checks how far it moved the version
+			mutate := func(what string, wantBump uint64, change func() error) {
+				t.Helper()
+				before := s.Version()
+				if err := change(); (err != nil) != (wantBump == 0) {
+					t.Fatalf("%s: unexpected error %v", what, err)
+				}
+				if got := s.Version() - before; got != wantBump {
+					t.Errorf("%s moved the version by %d, want %d", what, got, wantBump)
+				}
+			}
+
+			mutate("Save", 1, s.Save)
+			mutate("UpdateNotification", 1, func() error {
+				updated := *n
+				updated.Content = "second"
+				return s.UpdateNotification(&updated, "test")
+			})
+			mutate("committed Tx", 1, func() error {
+				return s.WithTransaction(func(tx *Tx) error {
+					if err := tx.AddNotification(&model.Notification{ID: "n2", Content: "a", Status: model.StatusPending}, "test"); err != nil {
+						return err
+					}
+					return tx.AddNotification(&model.Notification{ID: "n3", Content: "b", Status: model.StatusPending}, "test")
+				})
+			})
+			errFailed := errors.New("failed")
+			mutate("failed Tx", 0, func() error {
+				return s.WithTransaction(func(tx *Tx) error {
+					if err := tx.AddNotification(&model.Notification{ID: "n4", Content: "c", Status: model.StatusPending}, "test"); err != nil {
+						return err
+					}
+					return errFailed
+				})
+			})
+			if _, err := s.GetNotification("n4"); err == nil {
+				t.Error("failed Tx was not rolled back")
+			}
+			s.SetMaxPending(3)
+			mutate("Tx over the pending cap", 0, func() error {
+				return s.AddNotification(&model.Notification{ID: "n5", Content: "d", Status: model.StatusPending}, "test")
+			})
+		})
+	}
+}
+
+func TestLoadLegacyArray(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	legacy := `[{"id":"old","content":"Renew passport","status":"Pending","repeat_times":5},{"id":"older","content":"Call the bank","status":"Pending"}]`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(path, filepath.Join(dir, "audit.log"))
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	ns := s.GetAllNotifications()
+	if len(ns) != 2 {
+		t.Fatalf("loaded %d notifications, want 2", len(ns))
+	}
+	if ns[0].TotalSends != 5 {
+		t.Errorf("repeat_times migrated to TotalSends = %d, want 5", ns[0].TotalSends)
+	}
+	if ns[1].TotalSends != 3 || ns[1].RepeatInterval != "30m" {
+		t.Errorf("defaults: TotalSends = %d, RepeatInterval = %q; want 3, 30m", ns[1].TotalSends, ns[1].RepeatInterval)
+	}
+}
+
+func TestReloadDropsRemovedFields(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	s := NewStore(path, filepath.Join(dir, "audit.log"))
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	before := &model.Notification{ID: "n1", Content: "Feed the cat", Status: model.StatusPending, ImageURL: "https://example.com/cat.png", Paused: true}
+	if err := s.AddNotification(before, "test"); err != nil {
+		t.Fatalf("AddNotification: %v", err)
+	}
+
+	// Edited by hand: the image and the pause are taken out
+	edited := `{"settings":{},"notifications":[{"id":"n1","content":"Feed the cat","status":"Pending"}]}`
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	after, err := s.GetNotification("n1")
+	if err != nil {
+		t.Fatalf("GetNotification: %v", err)
+	}
+	if after.ImageURL != "" || after.Paused {
+		t.Errorf("after reload: ImageURL = %q, Paused = %v; want both removed", after.ImageURL, after.Paused)
+	}
+	if before.ImageURL == "" || !before.Paused {
+		t.Error("reload changed the notification read before it")
+	}
+}
+
+func TestSaveReplacesFileWhole(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json.gz")
+	s := NewStore(path, filepath.Join(dir, "audit.log"))
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Left over from a write cut short
+	if err := os.WriteFile(path+".tmp", []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &model.Notification{ID: "n1", Content: "Back up the laptop", Status: model.StatusPending}
+	if err := s.AddNotification(n, "test"); err != nil {
+		t.Fatalf("AddNotification: %v", err)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 0600 kept", perm)
+	}
+	reopened := NewStore(path, filepath.Join(dir, "audit.log"))
+	if err := reopened.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := reopened.GetNotification("n1"); err != nil {
+		t.Errorf("saved notification not found on reload: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrLocked is returned by AcquireLock while another process holds the lock
+var ErrLocked = errors.New("lock held by another process")
+
+// FileLock is an advisory lock on a file, held until Release or the process exits
+type FileLock struct {
+	f *os.File
+}
+
+// LockPath is the lock file guarding the data file at filePath
+func LockPath(filePath string) string {
+	return filePath + ".lock"
+}
+
+// AcquireLock takes an exclusive flock on path, creating it if needed, and fails with
+// ErrLocked if another process has it. The kernel releases the lock when its holder
+// exits, crashes included, so a lock file left behind is never stale: the next
+// process simply locks it again. The file records who holds it, for diagnosis only.
+// Filesystems without flock support, such as some network mounts, fail with another
+// error.
+func AcquireLock(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	hostname, _ := os.Hostname()
+	f.Truncate(0)
+	f.WriteAt([]byte(fmt.Sprintf("pid %d on %s since %s\n", os.Getpid(), hostname, time.Now().Format(time.RFC3339))), 0)
+	return &FileLock{f: f}, nil
+}
+
+// LockHolder returns what the lock file at path says about its holder, or "" if it
+// can't be read
+func LockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Release gives up the lock
+func (l *FileLock) Release() error {
+	return l.f.Close() // Closing the last descriptor drops the flock
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := LockPath(filepath.Join(t.TempDir(), "data.json"))
+
+	first, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("first AcquireLock: %v", err)
+	}
+	// A held lock must survive garbage collection for as long as it is referenced
+	runtime.GC()
+	runtime.GC()
+	if _, err := AcquireLock(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second AcquireLock = %v, want ErrLocked", err)
+	}
+	if LockHolder(path) == "" {
+		t.Error("lock file doesn't name its holder")
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	second, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock after Release: %v", err)
+	}
+	second.Release()
+}
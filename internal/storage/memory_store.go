@@ -0,0 +1,18 @@
+package storage
+
+// InMemoryStore keeps all data in memory for demos, CI and tests.
+// Save and Load are no-ops so nothing survives a restart; locking and
+// copy semantics are the same as the file-backed Store.
+type InMemoryStore struct {
+	*Store
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		Store: &Store{
+			memory: true,
+			Data:   defaultSchema(),
+			audit:  NewAuditLog(""),
+		},
+	}
+}
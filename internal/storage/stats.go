@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// SendCounts is how many send attempts succeeded and failed within a period
+type SendCounts struct {
+	Sent   int
+	Failed int
+}
+
+// SendStats counts the send attempts of the notifications matching match (all when
+// nil) made within each of windows before now, e.g. the last day and the last week.
+// Counts come from each notification's kept history, falling back to its last send
+// for data from before histories were kept; a notification repeating more often than
+// its history holds in a window is undercounted.
+func (s *Store) SendStats(now time.Time, match func(*model.Notification) bool, windows ...time.Duration) []SendCounts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make([]SendCounts, len(windows))
+	count := func(t time.Time, ok bool) {
+		age := now.Sub(t)
+		if age < 0 {
+			return
+		}
+		for i, window := range windows {
+			if age >= window {
+				continue
+			}
+			if ok {
+				counts[i].Sent++
+			} else {
+				counts[i].Failed++
+			}
+		}
+	}
+	for _, n := range s.Data.Notifications {
+		if match != nil && !match(n) {
+			continue
+		}
+		if len(n.History) == 0 {
+			if last := n.LastSentAt(); !last.IsZero() {
+				count(last, true)
+			}
+			continue
+		}
+		for _, a := range n.History {
+			count(a.Time, a.OK)
+		}
+	}
+	return counts
+}
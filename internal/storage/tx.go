@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// Tx is a batch of notification changes made inside WithTransaction.
+// Changes are visible to later calls on the same Tx, but are only saved,
+// and audited, once the whole transaction succeeds.
+type Tx struct {
+	data  *model.AppSchema
+	audit []model.AuditEvent
+}
+
+// WithTransaction runs fn under the store lock and saves once at the end. If fn
+// or the save fails, the in-memory data is rolled back and nothing is audited.
+// In degraded mode (see Health) a failed save keeps the changes instead.
+//
+// A transaction that would take the number of pending notifications past the cap
+// (see SetMaxPending) fails with ErrTooManyPending.
+//
+// Notifications must be changed through the Tx (e.g. UpdateNotification with a
+// modified copy) rather than in place: readers hold on to them outside the lock, and
+// rollback couldn't undo the change. Read them through the Tx too, so the copy starts
+// from the notification as it stands rather than as an earlier read found it.
+func (s *Store) WithTransaction(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	snapshot := append([]*model.Notification(nil), s.Data.Notifications...)
+	tx := &Tx{data: s.Data}
+
+	err := fn(tx)
+	// Only growth is refused, so a store already over the cap can still be edited
+	if after := countPending(s.Data.Notifications); err == nil && after > countPending(snapshot) && after > s.pendingLimitLocked() {
+		err = fmt.Errorf("%w: the limit is %d", ErrTooManyPending, s.pendingLimitLocked())
+	}
+	if err == nil {
+		s.version.Add(1)
+		if !s.memory {
+			err = s.persistLocked()
+		}
+	}
+	if err != nil {
+		s.Data.Notifications = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	for _, event := range tx.audit {
+		s.AppendAudit(event)
+	}
+	return nil
+}
+
+func countPending(ns []*model.Notification) int {
+	count := 0
+	for _, n := range ns {
+		if n.Status != model.StatusDone {
+			count++
+		}
+	}
+	return count
+}
+
+// Notifications returns the notifications as they stand in the transaction
+func (tx *Tx) Notifications() []*model.Notification {
+	return append([]*model.Notification(nil), tx.data.Notifications...)
+}
+
+func (tx *Tx) GetNotification(id string) (*model.Notification, error) {
+	for _, n := range tx.data.Notifications {
+		if n.ID == id {
+			return n, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// AddNotification adds n, unless the settings' DedupeMode catches it as a duplicate.
+// Rejected duplicates fail with a *DuplicateError; merged ones are dropped and n is
+// overwritten with the existing notification, so callers see its ID. An ID already
+// in the store fails with ErrIDExists.
+func (tx *Tx) AddNotification(n *model.Notification, actor string) error {
+	if n.ID == "" {
+		return fmt.Errorf("notification has no ID")
+	}
+	for _, existing := range tx.data.Notifications {
+		if existing.ID == n.ID {
+			return fmt.Errorf("%w: %s", ErrIDExists, n.ID)
+		}
+	}
+	if mode := tx.data.Settings.DedupeMode; mode != model.DedupeOff {
+		if existing := tx.findDuplicate(n); existing != nil {
+			if mode == model.DedupeMerge {
+				*n = *existing
+				return nil
+			}
+			return &DuplicateError{ExistingID: existing.ID}
+		}
+	}
+	tx.data.Notifications = append(tx.data.Notifications, n)
+	tx.audit = append(tx.audit, model.AuditEvent{Action: model.AuditCreate, NotificationID: n.ID, Content: n.Content, Actor: actor})
+	return nil
+}
+
+func (tx *Tx) UpdateNotification(updated *model.Notification, actor string) error {
+	for i, n := range tx.data.Notifications {
+		if n.ID == updated.ID {
+			tx.data.Notifications[i] = updated
+			tx.audit = append(tx.audit, model.AuditEvent{Action: model.AuditUpdate, NotificationID: updated.ID, Content: updated.Content, Actor: actor})
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ReplaceNotification is UpdateNotification without an audit event, for bookkeeping
+// such as the worker recording a send, which it audits as a send
+func (tx *Tx) ReplaceNotification(updated *model.Notification) error {
+	for i, n := range tx.data.Notifications {
+		if n.ID == updated.ID {
+			tx.data.Notifications[i] = updated
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (tx *Tx) DeleteNotification(id string, actor string) error {
+	for i, n := range tx.data.Notifications {
+		if n.ID == id {
+			tx.data.Notifications = append(tx.data.Notifications[:i], tx.data.Notifications[i+1:]...)
+			tx.audit = append(tx.audit, model.AuditEvent{Action: model.AuditDelete, NotificationID: id, Content: n.Content, Actor: actor})
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// findDuplicate returns the pending notification with n's owner, content and
+// scheduled time, if there is one
+func (tx *Tx) findDuplicate(n *model.Notification) *model.Notification {
+	for _, existing := range tx.data.Notifications {
+		if existing.Status != model.StatusDone && existing.OwnerID == n.OwnerID &&
+			existing.Content == n.Content && existing.ScheduledTime.Equal(n.ScheduledTime) {
+			return existing
+		}
+	}
+	return nil
+}
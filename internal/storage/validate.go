@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// ValidationError is a problem with one stored notification. Index is its position
+// in the data file, since the ID itself may be what's wrong.
+type ValidationError struct {
+	Index   int
+	ID      string
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("notification %d (id %q): %s", e.Index, e.ID, e.Problem)
+}
+
+// RepairResult counts what Repair changed
+type RepairResult struct {
+	Fixed       int
+	Quarantined int
+}
+
+// notificationProblems lists what is wrong with n; fatal ones can't be repaired
+// without guessing, so Repair quarantines the notification instead
+func notificationProblems(n *model.Notification) (problems []string, fatal bool) {
+	if n.Status != model.StatusPending && n.Status != model.StatusDone {
+		problems, fatal = append(problems, fmt.Sprintf("unknown status %q", n.Status)), true
+	}
+	if n.ScheduledTime.IsZero() {
+		problems, fatal = append(problems, "no scheduled time"), true
+	}
+	if n.ID == "" {
+		problems = append(problems, "empty ID")
+	}
+	if d, err := model.ParseInterval(n.RepeatInterval); err != nil || d <= 0 {
+		problems = append(problems, fmt.Sprintf("invalid repeat interval %q", n.RepeatInterval))
+	}
+	if n.TotalSends < 1 {
+		problems = append(problems, fmt.Sprintf("total sends %d is below 1", n.TotalSends))
+	}
+	if n.SendsCount < 0 {
+		problems = append(problems, fmt.Sprintf("negative sends count %d", n.SendsCount))
+	}
+	if n.Priority < -2 || n.Priority > 2 {
+		problems = append(problems, fmt.Sprintf("priority %d is outside -2 to 2", n.Priority))
+	}
+	for _, p := range n.Escalation {
+		if p < -2 || p > 2 {
+			problems = append(problems, fmt.Sprintf("escalation priority %d is outside -2 to 2", p))
+			break
+		}
+	}
+	return problems, fatal
+}
+
+// Validate checks every notification for a known status, a scheduled time, a
+// non-empty and unique ID, a parseable repeat interval and sensible counts and
+// priorities, returning a *ValidationError for each problem found
+func (s *Store) Validate() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	firstIndex := make(map[string]int) // Where each ID was first seen
+	for i, n := range s.Data.Notifications {
+		problems, _ := notificationProblems(n)
+		if first, ok := firstIndex[n.ID]; ok && n.ID != "" {
+			problems = append(problems, fmt.Sprintf("duplicate ID, also used by notification %d", first))
+		} else {
+			firstIndex[n.ID] = i
+		}
+		for _, p := range problems {
+			errs = append(errs, &ValidationError{Index: i, ID: n.ID, Problem: p})
+		}
+	}
+	return errs
+}
+
+// Repair fixes the problems Validate reports where there is a safe default: a new
+// ID (for all but the first of a duplicated one), the default repeat interval and total sends, a zero sends count or normal
+// priority. Notifications with an unknown status or no scheduled time are removed
+// and appended to quarantine.jsonl next to the data file, to be fixed by hand.
+func (s *Store) Repair(actor string) (RepairResult, error) {
+	var result RepairResult
+	err := s.WithTransaction(func(tx *Tx) error {
+		result = RepairResult{}
+		settings := tx.data.Settings
+		var kept, quarantined []*model.Notification
+		seen := make(map[string]bool) // IDs of the notifications kept so far
+		for _, n := range tx.data.Notifications {
+			problems, fatal := notificationProblems(n)
+			duplicate := seen[n.ID]
+			switch {
+			case len(problems) == 0 && !duplicate:
+				kept = append(kept, n)
+				seen[n.ID] = true
+			case fatal:
+				quarantined = append(quarantined, n)
+			default:
+				fixed := repairNotification(*n, settings)
+				if duplicate {
+					fixed.ID = uuid.New().String()
+				}
+				kept = append(kept, &fixed)
+				seen[fixed.ID] = true
+				result.Fixed++
+				tx.audit = append(tx.audit, model.AuditEvent{Action: model.AuditUpdate, NotificationID: fixed.ID, Content: fixed.Content, Actor: actor, Detail: "repaired"})
+			}
+		}
+		if len(quarantined) > 0 {
+			if err := s.quarantine(quarantined); err != nil {
+				return err
+			}
+			for _, n := range quarantined {
+				tx.audit = append(tx.audit, model.AuditEvent{Action: model.AuditDelete, NotificationID: n.ID, Content: n.Content, Actor: actor, Detail: "quarantined"})
+			}
+		}
+		result.Quarantined = len(quarantined)
+		tx.data.Notifications = append([]*model.Notification{}, kept...)
+		return nil
+	})
+	if err != nil {
+		return RepairResult{}, err
+	}
+	return result, nil
+}
+
+// repairNotification returns n with each fixable problem replaced by a default
+func repairNotification(n model.Notification, settings model.Settings) model.Notification {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	if d, err := model.ParseInterval(n.RepeatInterval); err != nil || d <= 0 {
+		n.RepeatInterval = "30m"
+		if d, err := model.ParseInterval(settings.RepeatInterval); err == nil && d > 0 {
+			n.RepeatInterval = settings.RepeatInterval
+		}
+	}
+	if n.TotalSends < 1 {
+		n.TotalSends = max(settings.TotalSends, 1)
+	}
+	n.SendsCount = max(n.SendsCount, 0)
+	if n.Priority < -2 || n.Priority > 2 {
+		n.Priority = 0
+	}
+	for _, p := range n.Escalation {
+		if p < -2 || p > 2 {
+			n.Escalation = nil
+			break
+		}
+	}
+	return n
+}
+
+// quarantine appends notifications removed by Repair to quarantine.jsonl next to the
+// data file, one JSON object per line; in memory mode they are only logged. The
+// caller must hold s.mu.
+func (s *Store) quarantine(ns []*model.Notification) error {
+	var lines []byte
+	for _, n := range ns {
+		line, err := json.Marshal(struct {
+			QuarantinedAt time.Time           `json:"quarantined_at"`
+			Notification  *model.Notification `json:"notification"`
+		}{time.Now(), n})
+		if err != nil {
+			return fmt.Errorf("failed to marshal quarantined notification: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if s.memory {
+		slog.Warn("Quarantined notifications", "records", string(lines))
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(s.filePath), "quarantine.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open quarantine file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(lines); err != nil {
+		return fmt.Errorf("failed to write quarantine file: %w", err)
+	}
+	return nil
+}
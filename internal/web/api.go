@@ -0,0 +1,265 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// findUserByAPIKey returns the user an API key belongs to
+func findUserByAPIKey(users []model.User, key string) *model.User {
+	if key == "" {
+		return nil
+	}
+	for i := range users {
+		if users[i].APIKey != "" && subtle.ConstantTimeCompare([]byte(users[i].APIKey), []byte(key)) == 1 {
+			u := users[i]
+			return &u
+		}
+	}
+	return nil
+}
+
+// apiKeyMiddleware authenticates /api/v1 requests by the key in an
+// "Authorization: Bearer" or "X-API-Key" header, acting as the key's user
+func (s *Server) apiKeyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = strings.TrimSpace(bearer)
+		}
+
+		user := findUserByAPIKey(s.store.GetSettings().Users, key)
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pushover-notify"`)
+			http.Error(w, "Invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	}
+}
+
+// handleAPIKey generates a new API key for the current user, revoking any old one
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	users := append([]model.User{}, settings.Users...)
+	for i := range users {
+		if users[i].ID == currentUser(r).ID {
+			users[i].APIKey = uuid.New().String()
+		}
+	}
+	settings.Users = users
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
+}
+
+// apiSummary is the body of GET /api/v1/summary
+type apiSummary struct {
+	Pending    int        `json:"pending"`
+	Done       int        `json:"done"`
+	Failed     int        `json:"failed"` // Pending, with the latest send attempt failed
+	NextSend   *time.Time `json:"next_send"`
+	WorkerIdle bool       `json:"worker_idle"`
+}
+
+// handleAPISummary reports notification counts and the next send for the key's
+// user, for status bars and dashboards
+func (s *Server) handleAPISummary(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	// As for the list, the version is read first, the user is part of the ETag and the
+	// tag is weak, covering the gzipped body too. The worker's next run covers idle
+	// changes that don't touch the store, like a mute ending.
+	etag := fmt.Sprintf(`W/"%s-%d-%s-%d"`, s.bootID, s.store.Version(), currentUser(r).ID, s.worker.Status().NextRun.Unix())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	var summary apiSummary
+	for _, n := range s.store.GetAllNotifications() {
+		if !canView(r, n) {
+			continue
+		}
+		if n.Status == model.StatusDone {
+			summary.Done++
+			continue
+		}
+		summary.Pending++
+		if n.LastError != "" {
+			summary.Failed++
+		}
+		if n.Paused {
+			continue
+		}
+		if next := s.worker.NextSendTime(n, settings); summary.NextSend == nil || next.Before(*summary.NextSend) {
+			summary.NextSend = &next
+		}
+	}
+	summary.WorkerIdle = s.worker.Status().Idle
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// apiNotification is the body of POST /api/v1/notifications. Only content and
+// scheduled_time are required; the rest default as in the add form.
+type apiNotification struct {
+	Content         string            `json:"content"`
+	Values          map[string]string `json:"values,omitempty"` // Filled into content when it is a template
+	ScheduledTime   string            `json:"scheduled_time"`   // e.g. "2025-01-01T09:00" (server time) or RFC 3339
+	TotalSends      int               `json:"total_sends,omitempty"`
+	RepeatInterval  string            `json:"repeat_interval,omitempty"` // e.g. "30m", "2h" or "1d"
+	RepeatUntil     string            `json:"repeat_until,omitempty"`    // Replaces total_sends when set
+	Priority        int               `json:"priority,omitempty"`
+	Escalation      []int             `json:"escalation,omitempty"`
+	ImageURL        string            `json:"image_url,omitempty"`
+	RequireAck      bool              `json:"require_ack,omitempty"`
+	SendOnce        bool              `json:"send_once,omitempty"`
+	WeekdaysOnly    bool              `json:"weekdays_only,omitempty"`
+	SendOnStartup   bool              `json:"send_on_startup,omitempty"`
+	AutoDeleteAfter string            `json:"auto_delete_after,omitempty"` // e.g. "7d"
+	LabelID         string            `json:"label_id,omitempty"`
+	SendWindowStart string            `json:"send_window_start,omitempty"`
+	SendWindowEnd   string            `json:"send_window_end,omitempty"`
+	Place           string            `json:"place,omitempty"`
+	Coordinates     string            `json:"coordinates,omitempty"` // "lat,long"
+}
+
+// handleAPICreateNotification adds a notification described by a JSON body and
+// responds 201 with its ID
+func (s *Server) handleAPICreateNotification(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+	if !canWrite(r) {
+		http.Error(w, tr(r, "error.read_only"), http.StatusForbidden)
+		return
+	}
+
+	// Form bodies are parsed before routing, so only a JSON body is left to read
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	var body apiNotification
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid JSON body", 400)
+		return
+	}
+	n, err := s.notificationFromAPI(body, currentUser(r).ID)
+	if err == nil {
+		err = s.checkLeadTime(r, s.store.GetSettings(), n.ScheduledTime)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := s.addNotification(w, r, n); err != nil {
+		http.Error(w, "Failed to save: "+err.Error(), addErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": n.ID})
+}
+
+// notificationFromAPI validates body as the add form's fields are validated
+func (s *Server) notificationFromAPI(body apiNotification, ownerID string) (*model.Notification, error) {
+	if len(body.Values) > model.MaxValues {
+		return nil, fmt.Errorf("Too many values (max %d)", model.MaxValues)
+	}
+	if err := checkContent(body.Content, body.Values); err != nil {
+		return nil, err
+	}
+	scheduled, err := s.parseScheduledTime(body.ScheduledTime)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid scheduled_time: %v", err)
+	}
+
+	settings := s.store.GetSettings()
+	n := &model.Notification{
+		ID:                  uuid.New().String(),
+		Content:             body.Content,
+		Values:              body.Values,
+		ScheduledTime:       scheduled,
+		Status:              model.StatusPending,
+		TotalSends:          settings.TotalSends,
+		RepeatInterval:      settings.RepeatInterval,
+		OwnerID:             ownerID,
+		StopOnFirstDelivery: body.SendOnce,
+		WeekdaysOnly:        body.WeekdaysOnly,
+		SendOnStartup:       body.SendOnStartup,
+		Escalation:          body.Escalation,
+	}
+	if body.TotalSends != 0 {
+		if n.TotalSends, err = s.parseTotalSends(strconv.Itoa(body.TotalSends)); err != nil {
+			return nil, err
+		}
+	}
+	if body.RepeatInterval != "" {
+		if d, err := intervalDuration(body.RepeatInterval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("Invalid repeat_interval: use a number and unit, e.g. 30m")
+		}
+		n.RepeatInterval = body.RepeatInterval
+	}
+	if body.RepeatUntil != "" {
+		if n.RepeatUntil, err = s.parseScheduledTime(body.RepeatUntil); err != nil || !n.RepeatUntil.After(scheduled) {
+			return nil, fmt.Errorf("Invalid repeat_until: must be a time after scheduled_time")
+		}
+	}
+	if n.Priority, err = parsePriority(strconv.Itoa(body.Priority)); err != nil {
+		return nil, err
+	}
+	for _, p := range body.Escalation {
+		if _, err := parsePriority(strconv.Itoa(p)); err != nil {
+			return nil, fmt.Errorf("Invalid escalation: use priorities between -2 and 2")
+		}
+	}
+	if n.ImageURL, err = parseImageURL(body.ImageURL); err != nil {
+		return nil, err
+	}
+	if body.RequireAck {
+		n.AckToken = uuid.New().String()
+	}
+	if body.AutoDeleteAfter != "" {
+		if n.AutoDeleteAfter, err = intervalDuration(body.AutoDeleteAfter); err != nil {
+			return nil, fmt.Errorf("Invalid auto_delete_after: use a number and unit, e.g. 7d")
+		}
+	}
+	if n.LabelID, err = s.parseLabelID(body.LabelID); err != nil {
+		return nil, err
+	}
+	if n.SendWindowStart, n.SendWindowEnd, err = parseSendWindow(body.SendWindowStart, body.SendWindowEnd); err != nil {
+		return nil, err
+	}
+	if n.Place, n.Coordinates, err = parseLocation(body.Place, body.Coordinates); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
@@ -0,0 +1,543 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/auth"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+type session struct {
+	UserID  string
+	Version int // User's SessionVersion when the session was created
+	Expires time.Time
+}
+
+type ctxKey int
+
+const userKey ctxKey = iota
+
+// setupNeeded reports whether no credentials have been configured yet
+func setupNeeded(settings model.Settings) bool {
+	return len(settings.Users) == 0 && settings.Password == ""
+}
+
+func findUser(users []model.User, id string) *model.User {
+	for i := range users {
+		if users[i].ID == id {
+			u := users[i]
+			return &u
+		}
+	}
+	return nil
+}
+
+func findUserByName(users []model.User, username string) *model.User {
+	for i := range users {
+		if strings.EqualFold(users[i].Username, username) {
+			u := users[i]
+			return &u
+		}
+	}
+	return nil
+}
+
+// currentUser returns the user authenticated by authMiddleware, or nil on public routes
+func currentUser(r *http.Request) *model.User {
+	u, _ := r.Context().Value(userKey).(*model.User)
+	return u
+}
+
+func isAdmin(r *http.Request) bool {
+	u := currentUser(r)
+	return u != nil && u.Role == model.RoleAdmin
+}
+
+func canWrite(r *http.Request) bool {
+	u := currentUser(r)
+	return u != nil && u.CanWrite()
+}
+
+// canView reports whether the current user may see n. Admins see everything.
+func canView(r *http.Request, n *model.Notification) bool {
+	u := currentUser(r)
+	return u != nil && (u.IsAdmin() || n.OwnerID == u.ID)
+}
+
+// canManage reports whether the current user may edit or delete n
+func canManage(r *http.Request, n *model.Notification) bool {
+	return canWrite(r) && canView(r, n)
+}
+
+// actor identifies the user and session (or API key) behind a request for the audit log.
+// Only a prefix of the token is recorded so the log can't be used to hijack sessions.
+func actor(r *http.Request) string {
+	cookie, err := r.Cookie("session_token")
+	if err != nil || cookie.Value == "" {
+		// API requests authenticate by key instead of a session
+		if u := currentUser(r); u != nil {
+			return u.Username + " (api key)"
+		}
+		return ""
+	}
+	token := cookie.Value
+	if len(token) > 8 {
+		token = token[:8]
+	}
+	if u := currentUser(r); u != nil {
+		return u.Username + " (session:" + token + ")"
+	}
+	return "session:" + token
+}
+
+// Session lifetimes used when none are configured
+const (
+	defaultSessionDuration  = 24 * time.Hour
+	defaultRememberDuration = 30 * 24 * time.Hour
+)
+
+// SetSessionDurations sets how long new sessions last, normally and with "remember me".
+// Zero values use the defaults.
+func (s *Server) SetSessionDurations(session, remember time.Duration) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.sessionDuration, s.rememberDuration = defaultSessionDuration, defaultRememberDuration
+	if session > 0 {
+		s.sessionDuration = session
+	}
+	if remember > 0 {
+		s.rememberDuration = remember
+	}
+}
+
+// sessionTTL is how long a new session lasts
+func (s *Server) sessionTTL(remember bool) time.Duration {
+	s.configMu.RLock()
+	defer s.configMu.RUnlock()
+	if remember {
+		return s.rememberDuration
+	}
+	return s.sessionDuration
+}
+
+func (s *Server) createSession(u *model.User, ttl time.Duration) (string, time.Time) {
+	token := uuid.New().String()
+	expires := time.Now().Add(ttl)
+
+	s.sessionsMu.Lock()
+	s.sessions[token] = session{UserID: u.ID, Version: u.SessionVersion, Expires: expires}
+	s.sessionsMu.Unlock()
+
+	return token, expires
+}
+
+func (s *Server) lookupSession(token string) (session, bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok || time.Now().After(sess.Expires) {
+		return session{}, false
+	}
+	return sess, true
+}
+
+func (s *Server) deleteSession(token string) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, token)
+	s.sessionsMu.Unlock()
+}
+
+// deleteUserSessions signs a user out everywhere
+func (s *Server) deleteUserSessions(userID string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	for token, sess := range s.sessions {
+		if sess.UserID == userID {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// resetUserSessions signs u out everywhere and gives the current request a fresh session
+// with the same expiry. Used after a password change so the user who made it stays signed in.
+func (s *Server) resetUserSessions(w http.ResponseWriter, r *http.Request, u *model.User) {
+	ttl := s.sessionTTL(false)
+	if cookie, err := r.Cookie("session_token"); err == nil {
+		if sess, ok := s.lookupSession(cookie.Value); ok {
+			ttl = time.Until(sess.Expires)
+		}
+	}
+	s.deleteUserSessions(u.ID)
+	token, expires := s.createSession(u, ttl)
+	s.setSessionCookie(w, r, token, expires)
+}
+
+// SetCookiePolicy sets the SameSite mode ("lax" or "strict") of the session cookie and
+// whether X-Forwarded-Proto from a reverse proxy is trusted to detect HTTPS
+func (s *Server) SetCookiePolicy(sameSite string, trustProxy bool) error {
+	switch strings.ToLower(sameSite) {
+	case "", "lax":
+		s.cookieSameSite = http.SameSiteLaxMode
+	case "strict":
+		s.cookieSameSite = http.SameSiteStrictMode
+	default:
+		return fmt.Errorf("invalid cookie SameSite mode %q (want lax or strict)", sameSite)
+	}
+	s.trustProxy = trustProxy
+	return nil
+}
+
+// isHTTPS reports whether the client connected over HTTPS, directly or through a trusted proxy
+func (s *Server) isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return s.trustProxy && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// setSessionCookie writes the session cookie; an empty token with a past expiry clears it
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_token",
+		Value:    token,
+		Path:     s.path("/"),
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   s.isHTTPS(r),
+		SameSite: s.cookieSameSite,
+	})
+}
+
+// Middleware
+func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := s.store.GetSettings()
+
+		if setupNeeded(settings) {
+			http.Redirect(w, r, s.path("/setup"), http.StatusSeeOther)
+			return
+		}
+
+		cookie, err := r.Cookie("session_token")
+		if err != nil || cookie.Value == "" {
+			s.redirectToLogin(w, r)
+			return
+		}
+
+		sess, ok := s.lookupSession(cookie.Value)
+		if !ok {
+			s.redirectToLogin(w, r)
+			return
+		}
+
+		// The user may have been removed, or changed their password, since the session was created
+		user := findUser(settings.Users, sess.UserID)
+		if user == nil || user.SessionVersion != sess.Version {
+			s.deleteSession(cookie.Value)
+			s.redirectToLogin(w, r)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	}
+}
+
+// redirectToLogin sends the browser to the login page, which returns it to the
+// requested page afterwards. Only page loads are returned to; a background HTMX
+// request or a form post would be meaningless to land on.
+func (s *Server) redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	target := s.path("/login")
+	if r.Method == "GET" && r.Header.Get("HX-Request") == "" && r.URL.Path != "/" {
+		target += "?next=" + url.QueryEscape(r.URL.RequestURI())
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// safeRedirect redirects to target, a path on this site below the base path such as
+// a "next" parameter, or to fallback if target is empty or could lead off the site
+func (s *Server) safeRedirect(w http.ResponseWriter, r *http.Request, target, fallback string) {
+	if !localPath(target) {
+		target = fallback
+	}
+	http.Redirect(w, r, s.path(target), http.StatusSeeOther)
+}
+
+// localPath reports whether next is a path on this site, such as "/settings",
+// rather than an absolute or protocol-relative URL that could point elsewhere
+func localPath(next string) bool {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return false
+	}
+	u, err := url.Parse(next)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
+
+// adminMiddleware is authMiddleware restricted to the admin role
+func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			http.Error(w, tr(r, "error.read_only"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// writerMiddleware is authMiddleware restricted to roles that can create notifications
+func (s *Server) writerMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.authMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		if !canWrite(r) {
+			http.Error(w, tr(r, "error.read_only"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Handlers
+
+func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	if !setupNeeded(settings) {
+		http.Redirect(w, r, s.path("/login"), http.StatusSeeOther)
+		return
+	}
+
+	if r.Method == "GET" {
+		s.renderTemplate(w, r, "setup.html", nil)
+		return
+	}
+
+	if r.Method == "POST" {
+		username := strings.TrimSpace(r.FormValue("username"))
+		if username == "" {
+			username = "admin"
+		}
+		password := r.FormValue("password")
+		if password == "" {
+			http.Error(w, "Password is required", 400)
+			return
+		}
+
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			http.Error(w, "Failed to hash password", 500)
+			return
+		}
+		settings.Users = []model.User{{ID: uuid.New().String(), Username: username, PasswordHash: hash, Role: model.RoleAdmin}}
+		if settings.RepeatInterval == "" {
+			settings.RepeatInterval = "30m"
+			settings.TotalSends = 3
+		}
+
+		if err := s.store.UpdateSettings(settings); err != nil {
+			http.Error(w, "Failed to save settings", 500)
+			return
+		}
+
+		s.worker.Refresh() // Trigger worker update
+
+		s.safeRedirect(w, r, r.FormValue("next"), "/login")
+	}
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// verifyUnknownUser burns the same time as a real password check so
+// response timing doesn't reveal which usernames exist
+func verifyUnknownUser(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = auth.HashPassword("dummy")
+	})
+	auth.VerifyPassword(dummyHash, password)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	if setupNeeded(settings) {
+		http.Redirect(w, r, s.path("/setup"), http.StatusSeeOther)
+		return
+	}
+
+	// The page to return to, carried from the query string through the form
+	next := r.FormValue("next")
+	if !localPath(next) {
+		next = "/"
+	}
+
+	if r.Method == "GET" {
+		s.renderTemplate(w, r, "login.html", map[string]interface{}{"Next": next})
+		return
+	}
+
+	if r.Method == "POST" {
+		username := strings.TrimSpace(r.FormValue("username"))
+		password := r.FormValue("password")
+
+		var user *model.User
+		if len(settings.Users) == 0 {
+			// Legacy single shared password: migrate it to an admin account on first login
+			if subtle.ConstantTimeCompare([]byte(password), []byte(settings.Password)) == 1 {
+				var err error
+				if user, err = s.migrateLegacyPassword(settings, username, password); err != nil {
+					http.Error(w, "Failed to migrate password", 500)
+					return
+				}
+			}
+		} else if u := findUserByName(settings.Users, username); u != nil {
+			if auth.VerifyPassword(u.PasswordHash, password) {
+				user = u
+			}
+		} else {
+			verifyUnknownUser(password)
+		}
+
+		if user == nil {
+			s.renderTemplate(w, r, "login.html", map[string]interface{}{"Error": tr(r, "login.invalid"), "Username": username, "Next": next})
+			return
+		}
+
+		ttl := s.sessionTTL(r.FormValue("remember") == "on")
+		sessionToken, expires := s.createSession(user, ttl)
+		s.setSessionCookie(w, r, sessionToken, expires)
+
+		s.safeRedirect(w, r, next, "/")
+	}
+}
+
+// migrateLegacyPassword replaces the shared plain text password with a hashed admin account
+func (s *Server) migrateLegacyPassword(settings model.Settings, username, password string) (*model.User, error) {
+	if username == "" {
+		username = "admin"
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{ID: uuid.New().String(), Username: username, PasswordHash: hash, Role: model.RoleAdmin}
+	settings.Users = []model.User{user}
+	settings.Password = ""
+	if err := s.store.UpdateSettings(settings); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	cookie, _ := r.Cookie("session_token")
+	if cookie != nil {
+		s.deleteSession(cookie.Value)
+	}
+	s.setSessionCookie(w, r, "", time.Now().Add(-1*time.Hour))
+	s.safeRedirect(w, r, r.FormValue("next"), "/login")
+}
+
+// handleAddUser creates a user from the settings page
+func (s *Server) handleAddUser(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	role := model.Role(r.FormValue("role"))
+	if username == "" || password == "" {
+		http.Error(w, "Username and password are required", 400)
+		return
+	}
+	if role != model.RoleAdmin && role != model.RoleUser && role != model.RoleViewer {
+		http.Error(w, "Invalid role", 400)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	if findUserByName(settings.Users, username) != nil {
+		http.Error(w, "Username already exists", 400)
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		http.Error(w, "Failed to hash password", 500)
+		return
+	}
+
+	// Copy before appending so the store's slice is never modified in place
+	users := append([]model.User{}, settings.Users...)
+	settings.Users = append(users, model.User{ID: uuid.New().String(), Username: username, PasswordHash: hash, Role: role})
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	http.Redirect(w, r, s.path("/settings"), http.StatusSeeOther)
+}
+
+// handleDeleteUser removes a user, refusing to remove yourself or the last admin
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == currentUser(r).ID {
+		http.Error(w, "You can't delete your own account", 400)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	var users []model.User
+	admins := 0
+	for _, u := range settings.Users {
+		if u.ID == id {
+			continue
+		}
+		if u.Role == model.RoleAdmin {
+			admins++
+		}
+		users = append(users, u)
+	}
+	if len(users) == len(settings.Users) {
+		http.Error(w, "User not found", 404)
+		return
+	}
+	if admins == 0 {
+		http.Error(w, "At least one admin is required", 400)
+		return
+	}
+
+	settings.Users = users
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	// Sessions of the removed user are rejected by authMiddleware on their next request
+	http.Redirect(w, r, s.path("/settings"), http.StatusSeeOther)
+}
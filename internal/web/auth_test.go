@@ -0,0 +1,163 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/auth"
+)
+
+// sessionCookie returns the session cookie set by rec, or nil
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "session_token" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestPasswordChangeEndsOtherSessions(t *testing.T) {
+	ts := newTestServer(t)
+	// Signed in on another device before the change
+	other, _ := ts.createSession(&ts.user, time.Hour)
+
+	form := ts.settingsForm()
+	form.Set("new_password", "correct horse battery staple")
+	rec := ts.do("POST", "/settings", form)
+	if rec.Code != http.StatusSeeOther || !strings.HasSuffix(rec.Header().Get("Location"), "/settings") {
+		t.Fatalf("password change: status = %d, Location = %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	get := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/settings", nil)
+		r.AddCookie(&http.Cookie{Name: "session_token", Value: token})
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, r)
+		return rec
+	}
+	for name, token := range map[string]string{"other device": other, "changing session": ts.session} {
+		if rec := get(token); rec.Code != http.StatusSeeOther || !strings.Contains(rec.Header().Get("Location"), "/login") {
+			t.Errorf("%s: status = %d, Location = %q; want a redirect to login", name, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+
+	// The user who made the change stays signed in, on a fresh session
+	fresh := sessionCookie(rec)
+	if fresh == nil || fresh.Value == ts.session {
+		t.Fatalf("no fresh session cookie after the change: %v", fresh)
+	}
+	if rec := get(fresh.Value); rec.Code != http.StatusOK {
+		t.Errorf("fresh session: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		next string
+		want bool
+	}{
+		{"/", true},
+		{"/settings", true},
+		{"/api/notifications/abc/edit?tab=history#top", true},
+		{"/%2F%2Fevil.com", true}, // A path with encoded slashes, not a host
+		{"", false},
+		{"settings", false},
+		{"//evil.com", false},
+		{"///evil.com", false},
+		{"//evil.com/settings", false},
+		{"https://evil.com", false},
+		{"https:evil.com", false},
+		{"javascript:alert(1)", false},
+		{"/\\evil.com", false},
+		{"/\\/evil.com", false},
+		{"\\\\evil.com", false},
+		{"/\t/evil.com", false},
+		{"/\n/evil.com", false},
+		{" //evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := localPath(tt.next); got != tt.want {
+			t.Errorf("localPath(%q) = %v, want %v", tt.next, got, tt.want)
+		}
+	}
+}
+
+// offSite reports whether a redirect to location could leave the site
+func offSite(location string) bool {
+	u, err := url.Parse(location)
+	return err != nil || u.Scheme != "" || u.Host != "" || !strings.HasPrefix(location, "/") ||
+		strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\")
+}
+
+func TestLoginRedirectStaysOnSite(t *testing.T) {
+	ts := newTestServer(t)
+	hash, err := auth.HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	settings := ts.store.GetSettings()
+	settings.Users[0].PasswordHash = hash
+	if err := ts.store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	// The next parameter as it appears in the query string, encoded or not
+	tests := []struct {
+		rawNext string
+		want    string // Expected Location; empty to only require it stays on the site
+	}{
+		{"%2Fsettings", "/settings"},
+		{"/audit", "/audit"},
+		{"//evil.com", "/"},
+		{"%2F%2Fevil.com", "/"},
+		{"%2F%252Fevil.com", ""},
+		{"https://evil.com", "/"},
+		{"https%3A%2F%2Fevil.com", "/"},
+		{"/%5Cevil.com", "/"},
+		{"%2F%5C%2Fevil.com", "/"},
+		{"%5C%5Cevil.com", "/"},
+		{"/%09/evil.com", "/"},
+		{"%2F%0D%0A%2Fevil.com", "/"},
+	}
+	for _, tt := range tests {
+		form := url.Values{"username": {"admin"}, "password": {"secret"}}
+		r := httptest.NewRequest("POST", "/login?next="+tt.rawNext, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, r)
+
+		location := rec.Header().Get("Location")
+		if rec.Code != http.StatusSeeOther {
+			t.Errorf("next=%s: status = %d, want 303", tt.rawNext, rec.Code)
+			continue
+		}
+		if offSite(location) {
+			t.Errorf("next=%s: redirected off the site to %q", tt.rawNext, location)
+		}
+		if tt.want != "" && location != tt.want {
+			t.Errorf("next=%s: redirected to %q, want %q", tt.rawNext, location, tt.want)
+		}
+	}
+}
+
+func TestSafeRedirectWithBasePath(t *testing.T) {
+	ts := newTestServer(t)
+	ts.SetBasePath("/reminders")
+	for next, want := range map[string]string{
+		"/settings":        "/reminders/settings",
+		"//evil.com":       "/reminders/login",
+		"https://evil.com": "/reminders/login",
+		"/\\evil.com":      "/reminders/login",
+	} {
+		rec := httptest.NewRecorder()
+		ts.safeRedirect(rec, httptest.NewRequest("GET", "/", nil), next, "/login")
+		if got := rec.Header().Get("Location"); got != want {
+			t.Errorf("safeRedirect(%q) to %q, want %q", next, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+const maxBulkLines = 500
+
+type bulkLineError struct {
+	Line int
+	Text string
+	Err  string
+}
+
+// parseBulkLines reads one "datetime | content" notification per line. Blank lines and
+// lines starting with # are skipped; every other line either parses or is reported.
+// Times are truncated to the scheduling precision like any other submitted time, and
+// checkTime vets each one.
+func (s *Server) parseBulkLines(text string, defaults model.Settings, ownerID string, checkTime func(time.Time) error) ([]*model.Notification, []bulkLineError) {
+	var (
+		created []*model.Notification
+		errs    []bulkLineError
+	)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lineNo := i + 1
+		if lineNo > maxBulkLines {
+			errs = append(errs, bulkLineError{Line: lineNo, Text: line, Err: fmt.Sprintf("only the first %d lines are read", maxBulkLines)})
+			break
+		}
+
+		datetimeStr, content, ok := strings.Cut(line, "|")
+		content = strings.TrimSpace(content)
+		if !ok || content == "" {
+			errs = append(errs, bulkLineError{Line: lineNo, Text: line, Err: `expected "datetime | content"`})
+			continue
+		}
+
+		if err := checkContent(content, nil); err != nil {
+			errs = append(errs, bulkLineError{Line: lineNo, Text: line, Err: err.Error()})
+			continue
+		}
+
+		scheduled, err := s.parseScheduledTime(datetimeStr)
+		if err != nil {
+			errs = append(errs, bulkLineError{Line: lineNo, Text: line, Err: "invalid datetime, use YYYY-MM-DDTHH:MM"})
+			continue
+		}
+		if err := checkTime(scheduled); err != nil {
+			errs = append(errs, bulkLineError{Line: lineNo, Text: line, Err: err.Error()})
+			continue
+		}
+
+		created = append(created, &model.Notification{
+			ID:             uuid.New().String(),
+			Content:        content,
+			ScheduledTime:  scheduled,
+			Status:         model.StatusPending,
+			TotalSends:     defaults.TotalSends,
+			RepeatInterval: defaults.RepeatInterval,
+			OwnerID:        ownerID,
+		})
+	}
+	return created, errs
+}
+
+// handleAPIBulkAdd creates the valid lines of a pasted list in one save and
+// reports the malformed ones by line number
+func (s *Server) handleAPIBulkAdd(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	created, errs := s.parseBulkLines(r.FormValue("lines"), settings, currentUser(r).ID, func(t time.Time) error {
+		return s.checkLeadTime(r, settings, t)
+	})
+	// Naming a group creates a new one holding every added line
+	if groupName := strings.TrimSpace(r.FormValue("group_name")); groupName != "" {
+		groupID := uuid.New().String()
+		for _, n := range created {
+			n.GroupID, n.GroupName = groupID, groupName
+		}
+	}
+	if len(created) > 0 {
+		if err := s.store.AddNotifications(created, actor(r)); err != nil {
+			http.Error(w, "Failed to save: "+err.Error(), addErrorStatus(err))
+			return
+		}
+		s.worker.Refresh()
+		s.broadcastRefresh()
+	}
+
+	s.renderPartial(w, r, "bulk_result", map[string]interface{}{
+		"Created": len(created),
+		"Errors":  errs,
+	})
+}
+
+// maxBulkDone bounds the IDs one bulk-done request may name
+const maxBulkDone = 500
+
+// handleAPIBulkDone marks the pending notifications named by the ids fields as Done,
+// counting it as their acknowledgement, in one transaction. IDs the user may not
+// manage, or that are already Done, are skipped.
+func (s *Server) handleAPIBulkDone(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	ids := r.Form["ids"]
+	if len(ids) == 0 {
+		http.Error(w, "No notifications selected", 400)
+		return
+	}
+	if len(ids) > maxBulkDone {
+		http.Error(w, fmt.Sprintf("Too many notifications selected (max %d)", maxBulkDone), 400)
+		return
+	}
+
+	now := time.Now()
+	var marked int
+	err := s.store.WithTransaction(func(tx *storage.Tx) error {
+		for _, id := range ids {
+			n, err := tx.GetNotification(id)
+			if err != nil || !canManage(r, n) || n.Status == model.StatusDone {
+				continue
+			}
+			updated := *n
+			updated.Status = model.StatusDone
+			updated.AcknowledgedAt = now
+			updated.RetryAt = time.Time{}
+			if err := tx.UpdateNotification(&updated, actor(r)); err != nil {
+				return err
+			}
+			marked++
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to update: "+err.Error(), 500)
+		return
+	}
+
+	if marked > 0 {
+		s.worker.Refresh()
+		s.broadcastRefresh()
+	}
+	s.renderNotificationsList(w, r)
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// iCalendar date-times: in UTC, and local to a TZID
+const (
+	icsTimeFormat      = "20060102T150405Z"
+	icsLocalTimeFormat = "20060102T150405"
+)
+
+// icsZoneHorizon is how far past the last event start the feed's time zone
+// description reaches, to cover the repeats that follow it
+const icsZoneHorizon = 366 * 24 * time.Hour
+
+// findUserByCalendarToken returns the user a calendar feed token belongs to
+func findUserByCalendarToken(users []model.User, token string) *model.User {
+	if token == "" {
+		return nil
+	}
+	for i := range users {
+		if users[i].CalendarToken == token {
+			u := users[i]
+			return &u
+		}
+	}
+	return nil
+}
+
+// calendarURL returns the subscription URL for u's feed, or "" if none has been generated
+func (s *Server) calendarURL(r *http.Request, u *model.User) string {
+	if u == nil || u.CalendarToken == "" {
+		return ""
+	}
+	scheme := "http"
+	if s.isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + s.path("/calendar.ics?token="+u.CalendarToken)
+}
+
+// handleCalendar serves pending notifications as an iCalendar feed. Calendar apps can't
+// log in, so the feed is authenticated by the per-user token in the query string.
+// Paused notifications are left out, as nothing is sent for them. Events start in the
+// server's time zone, so daily repeats stay at the same time of day across daylight
+// saving changes, as the worker sends them.
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	user := findUserByCalendarToken(s.store.GetSettings().Users, r.URL.Query().Get("token"))
+	if user == nil {
+		http.Error(w, "Invalid calendar token", http.StatusUnauthorized)
+		return
+	}
+
+	var b strings.Builder
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//pushover-notify//Reminders//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "X-WR-CALNAME:Pushover Notify")
+
+	var events []*model.Notification
+	var first, last time.Time
+	for _, n := range s.store.GetPending() {
+		if n.Paused || (!user.IsAdmin() && n.OwnerID != user.ID) {
+			continue
+		}
+		events = append(events, n)
+		if first.IsZero() || n.ScheduledTime.Before(first) {
+			first = n.ScheduledTime
+		}
+		last = latest(last, n.ScheduledTime, n.RepeatUntil)
+	}
+	tzid := localZoneName()
+	if len(events) > 0 {
+		writeVTimezone(&b, tzid, first.Truncate(s.precision), last.Add(icsZoneHorizon))
+	}
+
+	mode := s.store.GetSettings().SendMode
+	now := time.Now().UTC().Format(icsTimeFormat)
+	for _, n := range events {
+		writeICSLine(&b, "BEGIN:VEVENT")
+		// Stable UIDs let calendar apps update events in place rather than duplicate them
+		writeICSLine(&b, "UID:"+n.ID+"@pushover-notify")
+		writeICSLine(&b, "DTSTAMP:"+now)
+		writeICSLine(&b, "DTSTART;TZID="+tzid+":"+n.ScheduledTime.Truncate(s.precision).In(time.Local).Format(icsLocalTimeFormat))
+		writeICSLine(&b, "DURATION:PT15M")
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(n.Content))
+		if rrule := notificationRRule(n, mode); rrule != "" {
+			writeICSLine(&b, "RRULE:"+rrule)
+		}
+		writeICSLine(&b, "END:VEVENT")
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, b.String())
+}
+
+// notificationRRule describes a notification's repeats, the same series the worker sends:
+// SeriesLength sends, or sends until RepeatUntil, spaced RepeatInterval apart from the scheduled time
+func notificationRRule(n *model.Notification, mode string) string {
+	count := n.SeriesLength(mode)
+	if n.StopOnFirstDelivery || (count <= 1 && n.RepeatUntil.IsZero()) {
+		return ""
+	}
+
+	interval, err := intervalDuration(n.RepeatInterval)
+	if err != nil {
+		interval = 30 * time.Minute
+	}
+	end := fmt.Sprintf("COUNT=%d", count)
+	if !n.RepeatUntil.IsZero() {
+		end = "UNTIL=" + n.RepeatUntil.UTC().Format(icsTimeFormat)
+	}
+
+	freq, step := "SECONDLY", interval/time.Second
+	switch {
+	case interval%(24*time.Hour) == 0:
+		freq, step = "DAILY", interval/(24*time.Hour)
+	case interval%time.Hour == 0:
+		freq, step = "HOURLY", interval/time.Hour
+	case interval%time.Minute == 0:
+		freq, step = "MINUTELY", interval/time.Minute
+	}
+	return fmt.Sprintf("FREQ=%s;INTERVAL=%d;%s", freq, step, end)
+}
+
+// latest returns the latest of times
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, u := range times {
+		if u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
+
+// localZoneName names the server's time zone for TZID: its IANA name where it can be
+// found, as calendar apps recognise those, or "Local"
+func localZoneName() string {
+	if name := time.Local.String(); name != "Local" {
+		return name
+	}
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	return "Local"
+}
+
+// writeVTimezone describes the server's time zone from start to end as a VTIMEZONE,
+// which RFC 5545 requires for every TZID used. Each offset change in that span is
+// listed as it happens rather than as a rule, since Go doesn't expose the rules.
+func writeVTimezone(b *strings.Builder, tzid string, start, end time.Time) {
+	writeICSLine(b, "BEGIN:VTIMEZONE")
+	writeICSLine(b, "TZID:"+tzid)
+	for t := start.In(time.Local); ; {
+		name, offset := t.Zone()
+		from, _ := t.ZoneBounds()
+		prevOffset := offset
+		if !from.IsZero() {
+			_, prevOffset = from.Add(-time.Second).Zone()
+		} else {
+			from = start // The zone has always had this offset
+		}
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		writeICSLine(b, "BEGIN:"+kind)
+		// An observance starts at the local time it takes over from, before the change
+		writeICSLine(b, "DTSTART:"+from.In(time.FixedZone("", prevOffset)).Format(icsLocalTimeFormat))
+		writeICSLine(b, "TZOFFSETFROM:"+icsOffset(prevOffset))
+		writeICSLine(b, "TZOFFSETTO:"+icsOffset(offset))
+		writeICSLine(b, "TZNAME:"+escapeICSText(name))
+		writeICSLine(b, "END:"+kind)
+
+		_, next := t.ZoneBounds()
+		if next.IsZero() || next.After(end) {
+			break
+		}
+		t = next
+	}
+	writeICSLine(b, "END:VTIMEZONE")
+}
+
+// icsOffset formats a UTC offset in seconds as RFC 5545's UTC-OFFSET, e.g. +0130
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
+
+// escapeICSText escapes a TEXT value per RFC 5545
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine writes a content line folded at 75 octets, as RFC 5545 requires,
+// without splitting multi-byte characters
+func writeICSLine(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74 // Continuation lines start with a space
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// handleCalendarToken generates a new feed token for the current user, revoking any old one
+func (s *Server) handleCalendarToken(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	users := append([]model.User{}, settings.Users...)
+	for i := range users {
+		if users[i].ID == currentUser(r).ID {
+			users[i].CalendarToken = uuid.New().String()
+		}
+	}
+	settings.Users = users
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
+}
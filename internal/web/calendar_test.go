@@ -0,0 +1,78 @@
+package web
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+func TestNotificationRRule(t *testing.T) {
+	scheduled := time.Date(2026, time.October, 16, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		n    model.Notification
+		want string
+	}{
+		{"minutes", model.Notification{TotalSends: 3, RepeatInterval: "30m"}, "FREQ=MINUTELY;INTERVAL=30;COUNT=3"},
+		{"hours", model.Notification{TotalSends: 3, RepeatInterval: "2h"}, "FREQ=HOURLY;INTERVAL=2;COUNT=3"},
+		{"days", model.Notification{TotalSends: 3, RepeatInterval: "1d"}, "FREQ=DAILY;INTERVAL=1;COUNT=3"},
+		{"seconds", model.Notification{TotalSends: 3, RepeatInterval: "10s"}, "FREQ=SECONDLY;INTERVAL=10;COUNT=3"},
+		{"seconds past a minute", model.Notification{TotalSends: 3, RepeatInterval: "90s"}, "FREQ=SECONDLY;INTERVAL=90;COUNT=3"},
+		{"whole minutes in seconds", model.Notification{TotalSends: 3, RepeatInterval: "120s"}, "FREQ=MINUTELY;INTERVAL=2;COUNT=3"},
+		{"until", model.Notification{RepeatInterval: "1h", RepeatUntil: scheduled.Add(5 * time.Hour)}, "FREQ=HOURLY;INTERVAL=1;UNTIL=20261016T140000Z"},
+		{"single send", model.Notification{TotalSends: 1, RepeatInterval: "1h"}, ""},
+		{"send once", model.Notification{TotalSends: 3, RepeatInterval: "1h", StopOnFirstDelivery: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.n
+			n.ScheduledTime = scheduled
+			if got := notificationRRule(&n, ""); got != tt.want {
+				t.Errorf("rrule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarFeed(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("time zone Europe/Paris not available: %v", err)
+	}
+	saved := time.Local
+	time.Local = paris
+	t.Cleanup(func() { time.Local = saved })
+
+	ts := newTestServer(t)
+	settings := ts.store.GetSettings()
+	settings.Users[0].CalendarToken = "feed-token"
+	if err := ts.store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	// Daily at 9:00, across the end of summer time on October 25
+	scheduled := time.Date(2026, time.October, 20, 9, 0, 0, 0, paris)
+	ts.addNotification(t, &model.Notification{ID: "daily", Content: "Stand-up", ScheduledTime: scheduled, TotalSends: 10, RepeatInterval: "1d"})
+	ts.addNotification(t, &model.Notification{ID: "paused", Content: "On hold", ScheduledTime: scheduled, TotalSends: 3, RepeatInterval: "1h", Paused: true})
+
+	rec := ts.do("GET", "/calendar.ics?token=feed-token", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	feed := rec.Body.String()
+	for _, want := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Paris\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20260329T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nEND:DAYLIGHT\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20261025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD\r\n",
+		"DTSTART;TZID=Europe/Paris:20261020T090000\r\n",
+		"RRULE:FREQ=DAILY;INTERVAL=1;COUNT=10\r\n",
+	} {
+		if !strings.Contains(feed, want) {
+			t.Errorf("feed lacks %q:\n%s", want, feed)
+		}
+	}
+	if strings.Contains(feed, "On hold") {
+		t.Error("paused notification in the feed")
+	}
+}
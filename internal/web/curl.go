@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// apiKeyPlaceholder stands in for the user's key in generated commands, so they can
+// be shared without leaking it
+const apiKeyPlaceholder = "YOUR_API_KEY"
+
+// apiNotificationFor is the POST /api/v1/notifications body that recreates n with
+// its settings, scheduled for the same time
+func apiNotificationFor(n *model.Notification) apiNotification {
+	body := apiNotification{
+		Content:         n.Content,
+		Values:          n.Values,
+		ScheduledTime:   n.ScheduledTime.Format(time.RFC3339),
+		RepeatInterval:  n.RepeatInterval,
+		Priority:        n.Priority,
+		Escalation:      n.Escalation,
+		ImageURL:        n.ImageURL,
+		RequireAck:      n.AckToken != "",
+		SendOnce:        n.StopOnFirstDelivery,
+		WeekdaysOnly:    n.WeekdaysOnly,
+		SendOnStartup:   n.SendOnStartup,
+		LabelID:         n.LabelID,
+		SendWindowStart: n.SendWindowStart,
+		SendWindowEnd:   n.SendWindowEnd,
+		Place:           n.Place,
+		Coordinates:     n.Coordinates,
+	}
+	if n.RepeatUntil.IsZero() {
+		body.TotalSends = n.TotalSends
+	} else {
+		body.RepeatUntil = n.RepeatUntil.Format(time.RFC3339)
+	}
+	if n.AutoDeleteAfter > 0 {
+		value, unit := splitDuration(n.AutoDeleteAfter)
+		body.AutoDeleteAfter = strconv.Itoa(value) + unit
+	}
+	return body
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// handleAPIGetCurl renders a curl command that recreates the notification through the
+// JSON API, with a placeholder for the API key
+func (s *Server) handleAPIGetCurl(w http.ResponseWriter, r *http.Request, id string) {
+	n, err := s.store.GetNotification(id)
+	if err != nil {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	}
+
+	body, err := json.Marshal(apiNotificationFor(n))
+	if err != nil {
+		http.Error(w, "Failed to encode notification", 500)
+		return
+	}
+	scheme := "http"
+	if s.isHTTPS(r) {
+		scheme = "https"
+	}
+	command := strings.Join([]string{
+		"curl -X POST " + shellQuote(scheme+"://"+r.Host+s.path("/api/v1/notifications")),
+		"  -H " + shellQuote("Authorization: Bearer "+apiKeyPlaceholder),
+		"  -H 'Content-Type: application/json'",
+		"  -d " + shellQuote(string(body)),
+	}, " \\\n")
+
+	s.renderPartial(w, r, "curl_modal", map[string]interface{}{
+		"Command":     command,
+		"Placeholder": apiKeyPlaceholder,
+		"HasKey":      currentUser(r).APIKey != "",
+	})
+}
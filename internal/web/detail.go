@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// notificationDetail is everything the detail view shows about one notification
+type notificationDetail struct {
+	notificationView
+	NextSend     time.Time // Zero once Done or while paused
+	SeriesLength int       // Sends in the series; unused in repeat-until mode
+}
+
+// handleAPIGetDetail renders the read-only detail view of a notification
+func (s *Server) handleAPIGetDetail(w http.ResponseWriter, r *http.Request, id string) {
+	n, err := s.store.GetNotification(id)
+	if err != nil {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	detail := notificationDetail{
+		notificationView: notificationView{Notification: n, CanEdit: canManage(r, n) && !s.readOnly(), Label: findLabel(settings.Labels, n.LabelID)},
+		SeriesLength:     n.SeriesLength(settings.SendMode),
+	}
+	if n.StopOnFirstDelivery {
+		detail.SeriesLength = 1
+	}
+	if n.Status != model.StatusDone && !n.Paused {
+		detail.NextSend = s.worker.NextSendTime(n, settings)
+	}
+	s.renderPartial(w, r, "detail_modal", detail)
+}
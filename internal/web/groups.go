@@ -0,0 +1,126 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// groupNotifications moves each group's members up to where its first member is
+// listed and marks that first member, so the list can show a header row for it
+func groupNotifications(views []notificationView) []notificationView {
+	members := map[string][]notificationView{}
+	for _, v := range views {
+		if v.GroupID != "" {
+			members[v.GroupID] = append(members[v.GroupID], v)
+		}
+	}
+
+	grouped := make([]notificationView, 0, len(views))
+	for _, v := range views {
+		if v.GroupID == "" {
+			grouped = append(grouped, v)
+			continue
+		}
+		group, ok := members[v.GroupID]
+		if !ok {
+			continue // Already listed with its first member
+		}
+		delete(members, v.GroupID)
+
+		header := &groupView{ID: v.GroupID, Name: v.GroupName, Size: len(group)}
+		for _, m := range group {
+			header.Paused = header.Paused || m.Paused
+			header.CanEdit = header.CanEdit || m.CanEdit
+		}
+		group[0].Group = header
+		grouped = append(grouped, group...)
+	}
+	return grouped
+}
+
+// groupView is the header row shown above a group's members
+type groupView struct {
+	ID      string
+	Name    string
+	Size    int
+	Paused  bool // Some member is paused, so the group action resumes
+	CanEdit bool
+}
+
+// handleAPIGroup pauses or resumes (POST .../pause) or deletes (DELETE) the members
+// of a group the current user may manage
+func (s *Server) handleAPIGroup(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/groups/")
+	groupID, action, _ := strings.Cut(path, "/")
+
+	var members []*model.Notification
+	for _, n := range s.store.GetGroup(groupID) {
+		if canManage(r, n) {
+			members = append(members, n)
+		}
+	}
+	if len(members) == 0 {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	}
+
+	var err error
+	switch action {
+	case "pause":
+		if !allowMethods(w, r, "POST") {
+			return
+		}
+		err = s.store.WithTransaction(func(tx *storage.Tx) error {
+			return pauseGroup(tx, members, r.FormValue("paused") == "true", actor(r))
+		})
+	case "":
+		if !allowMethods(w, r, "DELETE") {
+			return
+		}
+		err = s.store.WithTransaction(func(tx *storage.Tx) error {
+			for _, n := range members {
+				if err := tx.DeleteNotification(n.ID, actor(r)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update group", 500)
+		return
+	}
+
+	s.worker.Refresh()
+	s.broadcastRefresh()
+	s.renderNotificationsList(w, r)
+}
+
+// pauseGroup sets the paused state of every member that changes. Members are read
+// again through tx, so changes made since they were listed are kept.
+func pauseGroup(tx *storage.Tx, members []*model.Notification, paused bool, actor string) error {
+	for _, member := range members {
+		n, err := tx.GetNotification(member.ID)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue // Deleted meanwhile
+		} else if err != nil {
+			return err
+		}
+		if n.Paused == paused {
+			continue
+		}
+		updated := *n
+		updated.Paused = paused
+		if err := tx.UpdateNotification(&updated, actor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
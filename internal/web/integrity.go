@@ -0,0 +1,45 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// integrityReport is the JSON body of /api/integrity
+type integrityReport struct {
+	Problems    []string `json:"problems"`
+	Fixed       int      `json:"fixed,omitempty"`
+	Quarantined int      `json:"quarantined,omitempty"`
+}
+
+// handleAPIIntegrity reports problems with the stored notifications (GET) or repairs
+// them (POST): fixable fields are reset to defaults and the rest are moved to the
+// quarantine file. The report lists the problems left afterwards.
+func (s *Server) handleAPIIntegrity(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	var report integrityReport
+	if r.Method == "POST" {
+		result, err := s.store.Repair(actor(r))
+		if err != nil {
+			http.Error(w, "Failed to repair: "+err.Error(), 500)
+			return
+		}
+		report.Fixed, report.Quarantined = result.Fixed, result.Quarantined
+		if result.Fixed > 0 || result.Quarantined > 0 {
+			slog.Info("Stored notifications repaired", "fixed", result.Fixed, "quarantined", result.Quarantined, "by", actor(r))
+			s.worker.Refresh()
+			s.broadcastRefresh()
+		}
+	}
+
+	report.Problems = []string{}
+	for _, err := range s.store.Validate() {
+		report.Problems = append(report.Problems, err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,114 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// findLabel returns the label with the given ID, or nil
+func findLabel(labels []model.Label, id string) *model.Label {
+	for i := range labels {
+		if labels[i].ID == id {
+			l := labels[i]
+			return &l
+		}
+	}
+	return nil
+}
+
+// parseLabelID checks that a submitted label exists; empty means no label
+func (s *Server) parseLabelID(id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	if findLabel(s.store.GetSettings().Labels, id) == nil {
+		return "", fmt.Errorf("Unknown label")
+	}
+	return id, nil
+}
+
+// handleAddLabel creates a label from the settings page
+func (s *Server) handleAddLabel(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	color := r.FormValue("color")
+	if name == "" {
+		http.Error(w, "Label name is required", 400)
+		return
+	}
+	if !labelColorPattern.MatchString(color) {
+		http.Error(w, "Invalid color: use a hex value like #3b82f6", 400)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	for _, l := range settings.Labels {
+		if strings.EqualFold(l.Name, name) {
+			http.Error(w, "Label already exists", 400)
+			return
+		}
+	}
+
+	// Copy before appending so the store's slice is never modified in place
+	labels := append([]model.Label{}, settings.Labels...)
+	settings.Labels = append(labels, model.Label{ID: uuid.New().String(), Name: name, Color: strings.ToLower(color)})
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	http.Redirect(w, r, s.path("/settings"), http.StatusSeeOther)
+}
+
+// handleDeleteLabel removes a label and untags the notifications that used it
+func (s *Server) handleDeleteLabel(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	id := r.FormValue("id")
+	settings := s.store.GetSettings()
+	var labels []model.Label
+	for _, l := range settings.Labels {
+		if l.ID != id {
+			labels = append(labels, l)
+		}
+	}
+	settings.Labels = labels
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	err := s.store.WithTransaction(func(tx *storage.Tx) error {
+		for _, n := range tx.Notifications() {
+			if n.LabelID != id {
+				continue
+			}
+			updated := *n
+			updated.LabelID = ""
+			if err := tx.UpdateNotification(&updated, actor(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to untag notifications", 500)
+		return
+	}
+	s.broadcastRefresh()
+
+	http.Redirect(w, r, s.path("/settings"), http.StatusSeeOther)
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/i18n"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// maxLeadMinutes caps the minimum lead time setting at a day
+const maxLeadMinutes = 1440
+
+// checkLeadTime rejects a new notification scheduled sooner than the minimum lead time
+// in settings allows, naming the earliest time that would be accepted. This guards
+// against reminders that would send at once by mistake, past times included.
+func (s *Server) checkLeadTime(r *http.Request, settings model.Settings, scheduled time.Time) error {
+	if settings.MinLeadMinutes <= 0 {
+		return nil
+	}
+
+	// Rounded up, as scheduled times are truncated to the minute (or second)
+	earliest := time.Now().Add(time.Duration(settings.MinLeadMinutes) * time.Minute)
+	if rounded := earliest.Truncate(s.precision); rounded.Before(earliest) {
+		earliest = rounded.Add(s.precision)
+	}
+	if !scheduled.Before(earliest) {
+		return nil
+	}
+
+	style := "datetime"
+	if s.precision < time.Minute {
+		style = "datetime_seconds"
+	}
+	return fmt.Errorf("Scheduled time must be at least %d minutes from now; the earliest allowed is %s",
+		settings.MinLeadMinutes, i18n.FormatTime(requestLocale(r), style, earliest))
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/noahxzhu/pushover-notify/internal/i18n"
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// requestLocale is the signed-in user's chosen language, or else the best match for
+// the browser's Accept-Language header
+func requestLocale(r *http.Request) string {
+	if u := currentUser(r); u != nil && i18n.Supported(u.Locale) {
+		return u.Locale
+	}
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// tr translates a UI message for r's locale
+func tr(r *http.Request, key string, args ...any) string {
+	return i18n.T(requestLocale(r), key, args...)
+}
+
+// handleLanguage saves the current user's UI language; an empty locale goes back to
+// following the browser
+func (s *Server) handleLanguage(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	locale := r.FormValue("locale")
+	if locale != "" && !i18n.Supported(locale) {
+		http.Error(w, tr(r, "error.locale"), 400)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	users := append([]model.User{}, settings.Users...)
+	for i := range users {
+		if users[i].ID == currentUser(r).ID {
+			users[i].Locale = locale
+		}
+	}
+	settings.Users = users
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	http.Redirect(w, r, s.path("/"), http.StatusSeeOther)
+}
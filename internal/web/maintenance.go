@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maintenanceExempt lists paths that keep accepting changes in maintenance mode: signing
+// in and out, first-run setup, switching the mode back off, and the side-effect-free
+// message and schedule previews
+var maintenanceExempt = map[string]bool{
+	"/login":                      true,
+	"/logout":                     true,
+	"/setup":                      true,
+	"/api/maintenance":            true,
+	"/api/notifications/preview":  true,
+	"/api/notifications/schedule": true,
+}
+
+// SetMaintenance turns maintenance mode on or off. While on, the data is read-only:
+// requests that would change it get 503 and the worker stops sending.
+func (s *Server) SetMaintenance(on bool) {
+	s.maintenance.Store(on)
+	s.worker.SetPaused(on)
+}
+
+// SetStandby makes this instance read-only, like maintenance mode, while another one
+// holds the sender lock on the same data file, or returns it to normal once it has
+// taken the lock over. Unlike maintenance mode it can't be switched off from the UI.
+func (s *Server) SetStandby(on bool) {
+	if s.standby.Swap(on) == on {
+		return
+	}
+	s.worker.SetStandby(on)
+	s.broadcast("maintenance", "changed") // Controls across the page depend on it
+}
+
+// readOnly reports whether changes are rejected: in maintenance mode or on standby
+func (s *Server) readOnly() bool {
+	return s.maintenance.Load() || s.standby.Load()
+}
+
+// maintenanceGuard rejects changes while maintenance mode is on or the instance is on
+// standby. Acknowledge links are GETs but mark notifications done, so they are
+// rejected too.
+func (s *Server) maintenanceGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly() && !maintenanceExempt[r.URL.Path] {
+			safe := r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS"
+			if !safe || strings.HasPrefix(r.URL.Path, "/ack/") {
+				message := tr(r, "error.maintenance")
+				if s.standby.Load() {
+					message = tr(r, "error.standby")
+				}
+				w.Header().Set("Retry-After", "300")
+				http.Error(w, message, http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAPIMaintenance reports (GET) or switches (POST enabled=on|off) maintenance mode.
+// The change lasts until restart; server.maintenance in the config sets the initial state.
+func (s *Server) handleAPIMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	if r.Method == "POST" {
+		var on bool
+		switch r.FormValue("enabled") {
+		case "on", "true", "1":
+			on = true
+		case "off", "false", "0":
+		default:
+			http.Error(w, "enabled must be on or off", 400)
+			return
+		}
+
+		if on != s.maintenance.Load() {
+			s.SetMaintenance(on)
+			slog.Info("Maintenance mode changed", "enabled", on, "by", actor(r))
+			s.broadcast("maintenance", "changed")
+		}
+
+		// Controls across the page depend on the mode, so reload it
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Refresh", "true")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"maintenance": s.maintenance.Load()})
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// sortNotifications sorts views in one of the model.ListOrder orders. views must be
+// in storage order, which is the order the notifications were added.
+func (s *Server) sortNotifications(views []notificationView, order string, settings model.Settings) {
+	if order == model.ListOrderNewest {
+		slices.Reverse(views)
+	}
+
+	var next map[string]time.Time
+	if order == model.ListOrderNextDue {
+		next = make(map[string]time.Time, len(views))
+		for _, v := range views {
+			if v.Status == model.StatusPending {
+				next[v.ID] = s.worker.NextSendTime(v.Notification, settings)
+			}
+		}
+	}
+
+	sort.SliceStable(views, func(i, j int) bool {
+		a, b := views[i], views[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		switch order {
+		case model.ListOrderNewest:
+			return false // Already newest first
+		case model.ListOrderNextDue:
+			aNext, aPending := next[a.ID]
+			bNext, bPending := next[b.ID]
+			if aPending != bPending {
+				return aPending
+			}
+			if aPending {
+				return aNext.Before(bNext)
+			}
+			return a.DoneAt().After(b.DoneAt())
+		}
+		return a.ScheduledTime.Before(b.ScheduledTime)
+	})
+}
+
+// handleListOrder saves the current user's list order and responds with the list
+// sorted that way
+func (s *Server) handleListOrder(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	order := r.FormValue("order")
+	switch order {
+	case model.ListOrderScheduled, model.ListOrderNextDue, model.ListOrderNewest:
+	default:
+		http.Error(w, "Invalid list order", 400)
+		return
+	}
+
+	settings := s.store.GetSettings()
+	users := append([]model.User{}, settings.Users...)
+	for i := range users {
+		if users[i].ID == currentUser(r).ID {
+			users[i].ListOrder = order
+		}
+	}
+	settings.Users = users
+	if err := s.store.UpdateSettings(settings); err != nil {
+		http.Error(w, "Failed to update settings", 500)
+		return
+	}
+
+	// The request's user predates the change
+	user := *currentUser(r)
+	user.ListOrder = order
+	s.renderNotificationsList(w, r.WithContext(context.WithValue(r.Context(), userKey, &user)))
+}
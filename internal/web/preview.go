@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
+)
+
+type previewParam struct {
+	Name  string
+	Value string
+}
+
+// handleAPIPreviewNotification shows the fields Pushover would receive for the add form's
+// first send, without saving or sending anything
+func (s *Server) handleAPIPreviewNotification(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	data := map[string]interface{}{}
+	imageURL, err := parseImageURL(r.FormValue("image_url"))
+	if err != nil {
+		data["Error"] = err.Error()
+		s.renderPartial(w, r, "message_preview", data)
+		return
+	}
+
+	n := &model.Notification{ImageURL: imageURL}
+	if n.Content, n.Values, err = formContent(r); err == nil {
+		n.Priority, err = parsePriority(r.FormValue("priority"))
+	}
+	if err == nil {
+		n.Escalation, err = parseEscalation(r.FormValue("escalation"))
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+		s.renderPartial(w, r, "message_preview", data)
+		return
+	}
+	if r.FormValue("require_ack") == "on" {
+		n.AckToken = "TOKEN" // The real token is generated on save
+	}
+
+	settings := s.store.GetSettings()
+	msg := s.worker.BuildMessage(n, settings)
+	params, err := pushover.NewClient(settings.PushoverToken, settings.PushoverUser).Params(msg)
+	if err != nil {
+		data["Error"] = err.Error()
+		s.renderPartial(w, r, "message_preview", data)
+		return
+	}
+
+	var fields []previewParam
+	for _, name := range []string{"title", "message", "html", "priority", "retry", "expire", "url", "url_title"} {
+		if v := params.Get(name); v != "" {
+			fields = append(fields, previewParam{Name: name, Value: v})
+		}
+	}
+	data["Params"] = fields
+	data["ImageURL"] = imageURL
+	data["HasHTML"] = strings.ContainsAny(n.Content, "<&")
+	data["AckWithoutURL"] = n.AckToken != "" && msg.URL == ""
+	s.renderPartial(w, r, "message_preview", data)
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// defaultSnooze is how long a "snooze" reply without a duration holds the next send back
+const defaultSnooze = time.Hour
+
+// SetReplies turns the /reply endpoint on or off, and with it the reply reference the
+// worker quotes in messages
+func (s *Server) SetReplies(enabled bool) {
+	s.replies.Store(enabled)
+	s.worker.SetReplies(enabled)
+}
+
+// replyPayload is what a reply bridge posts: the token quoted in the message replied
+// to and the reply's text. Form fields of the same names work too.
+type replyPayload struct {
+	Token string `json:"token"`
+	Reply string `json:"reply"`
+}
+
+// handleReply receives a reply to a message, records it in the audit log and acts on
+// it: "done" marks the notification Done, "snooze 1h" holds its next send back
+func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
+	if !s.replies.Load() {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	payload := replyPayload{Token: r.FormValue("token"), Reply: r.FormValue("reply")}
+	// Form bodies are parsed before routing, so only a JSON body is left to read
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "Invalid JSON body", 400)
+			return
+		}
+	}
+	payload.Token = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(payload.Token), "Ref:"))
+
+	n := s.findByReplyToken(payload.Token)
+	if n == nil {
+		http.Error(w, "Unknown reply token", 404)
+		return
+	}
+	s.store.AppendAudit(model.AuditEvent{Action: model.AuditReply, NotificationID: n.ID, Content: n.Content, Actor: "reply", Detail: payload.Reply})
+
+	if err := s.applyReply(n, payload.Reply); err != nil {
+		http.Error(w, err.Error(), replyErrorStatus(err))
+		return
+	}
+	slog.Info("Reply received", "id", n.ID, "reply", payload.Reply)
+
+	s.worker.Refresh()
+	s.broadcastRefresh()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errUnknownReply is returned for a reply that isn't "done" or "snooze"
+var errUnknownReply = errors.New(`Unknown reply: use "done" or "snooze" with an optional duration, e.g. "snooze 1h"`)
+
+// applyReply carries out a reply to n
+func (s *Server) applyReply(n *model.Notification, reply string) error {
+	words := strings.Fields(strings.ToLower(reply))
+	if len(words) == 0 {
+		return errUnknownReply
+	}
+	switch words[0] {
+	case "done", "ok", "ack":
+		_, err := s.store.AcknowledgeReply(n.ReplyToken)
+		return err
+	case "snooze":
+		snooze := defaultSnooze
+		if len(words) > 1 {
+			d, err := model.ParseInterval(words[1])
+			if err != nil || d <= 0 {
+				return errUnknownReply
+			}
+			snooze = d
+		}
+		return s.store.WithTransaction(func(tx *storage.Tx) error {
+			current, err := tx.GetNotification(n.ID)
+			if err != nil {
+				return err
+			}
+			if current.Status == model.StatusDone {
+				return errReplyDone
+			}
+			updated := *current
+			updated.SnoozedUntil = time.Now().Add(snooze).Truncate(s.precision)
+			return tx.UpdateNotification(&updated, "reply")
+		})
+	}
+	return errUnknownReply
+}
+
+// errReplyDone is returned when snoozing a notification that is already Done
+var errReplyDone = errors.New("Notification is already done")
+
+// replyErrorStatus is the HTTP status for an error from applyReply
+func replyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errUnknownReply):
+		return 400
+	case errors.Is(err, errReplyDone):
+		return 409
+	}
+	return 500
+}
+
+// findByReplyToken returns the notification whose messages quote token, or nil
+func (s *Server) findByReplyToken(token string) *model.Notification {
+	if token == "" {
+		return nil
+	}
+	for _, n := range s.store.GetAllNotifications() {
+		if n.ReplyToken == token {
+			return n
+		}
+	}
+	return nil
+}
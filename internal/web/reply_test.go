@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// postReply posts a reply the way a bridge does: a form, without a session
+func postReply(ts *testServer, token, reply string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {token}, "reply": {reply}}
+	r := httptest.NewRequest("POST", "/reply", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	ts.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string // Prefixed to the stored token, as a bridge may quote it
+		reply       string
+		wantStatus  int
+		wantDone    bool
+		wantSnooze  time.Duration
+		alreadyDone bool
+	}{
+		{name: "done", reply: "done", wantStatus: http.StatusNoContent, wantDone: true},
+		{name: "done quoting the reference", token: "Ref: ", reply: "Done", wantStatus: http.StatusNoContent, wantDone: true},
+		{name: "snooze with duration", reply: "snooze 2h", wantStatus: http.StatusNoContent, wantSnooze: 2 * time.Hour},
+		{name: "snooze default", reply: "snooze", wantStatus: http.StatusNoContent, wantSnooze: defaultSnooze},
+		{name: "snooze bad duration", reply: "snooze soon", wantStatus: http.StatusBadRequest},
+		{name: "unknown reply", reply: "thanks", wantStatus: http.StatusBadRequest},
+		{name: "snooze when done", reply: "snooze 1h", wantStatus: http.StatusConflict, alreadyDone: true, wantDone: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.SetReplies(true)
+			n := &model.Notification{
+				ID:             "n1",
+				Content:        "Take the bins out",
+				Status:         model.StatusPending,
+				ScheduledTime:  time.Now().Add(-time.Minute),
+				TotalSends:     3,
+				RepeatInterval: "30m",
+				ReplyToken:     "reply-token",
+				SendsCount:     1,
+				LastPushTime:   time.Now().Add(-time.Minute),
+			}
+			if tt.alreadyDone {
+				n.Status = model.StatusDone
+			}
+			ts.addNotification(t, n)
+
+			before := time.Now()
+			rec := postReply(ts, tt.token+"reply-token", tt.reply)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, strings.TrimSpace(rec.Body.String()), tt.wantStatus)
+			}
+
+			got, err := ts.store.GetNotification("n1")
+			if err != nil {
+				t.Fatalf("GetNotification: %v", err)
+			}
+			if done := got.Status == model.StatusDone; done != tt.wantDone {
+				t.Errorf("Done = %v, want %v", done, tt.wantDone)
+			}
+			if tt.wantSnooze == 0 {
+				if !got.SnoozedUntil.IsZero() {
+					t.Errorf("SnoozedUntil = %s, want unset", got.SnoozedUntil)
+				}
+				return
+			}
+			earliest := before.Add(tt.wantSnooze).Truncate(time.Minute)
+			if got.SnoozedUntil.Before(earliest) || got.SnoozedUntil.After(time.Now().Add(tt.wantSnooze)) {
+				t.Errorf("SnoozedUntil = %s, want %s from now", got.SnoozedUntil, tt.wantSnooze)
+			}
+			if next := ts.worker.NextSendTime(got, model.Settings{}); next.Before(got.SnoozedUntil) {
+				t.Errorf("next send at %s, before the snooze ends at %s", next, got.SnoozedUntil)
+			}
+		})
+	}
+}
+
+func TestReplyUnknownOrDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	if rec := postReply(ts, "reply-token", "done"); rec.Code != http.StatusNotFound {
+		t.Errorf("replies off: status = %d, want 404", rec.Code)
+	}
+	ts.SetReplies(true)
+	if rec := postReply(ts, "no-such-token", "done"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown token: status = %d, want 404", rec.Code)
+	}
+	if rec := postReply(ts, "", "done"); rec.Code != http.StatusNotFound {
+		t.Errorf("empty token: status = %d, want 404", rec.Code)
+	}
+}
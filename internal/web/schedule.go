@@ -0,0 +1,108 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// maxScheduleTimes caps how many send times the schedule preview lists
+const maxScheduleTimes = 50
+
+// scheduledSend is one line of the schedule preview
+type scheduledSend struct {
+	Number int // Position in the series, counting sends already made
+	Time   time.Time
+}
+
+// handleAPIScheduleNotification lists the send times the add or edit form would give,
+// worked out by the worker's own scheduling, without saving anything. With an id it
+// previews an edit of that notification, counting the sends it has already made.
+func (s *Server) handleAPIScheduleNotification(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	settings := s.store.GetSettings()
+	data := map[string]interface{}{}
+	n, err := s.scheduleFromForm(r, settings)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	} else if err != nil {
+		data["Error"] = err.Error()
+		s.renderPartial(w, r, "schedule_preview", data)
+		return
+	}
+	if r.FormValue("id") == "" {
+		// A new notification's jitter depends on the ID it gets on save
+		data["Jitter"] = settings.JitterSeconds
+		settings.JitterSeconds = 0
+	}
+
+	times := s.worker.Schedule(n, settings, maxScheduleTimes+1)
+	if len(times) > maxScheduleTimes {
+		times = times[:maxScheduleTimes]
+		data["More"] = true
+	}
+	sends := make([]scheduledSend, len(times))
+	for i, t := range times {
+		sends[i] = scheduledSend{Number: n.SendsCount + i + 1, Time: t}
+	}
+	data["Times"] = sends
+	s.renderPartial(w, r, "schedule_preview", data)
+}
+
+// scheduleFromForm builds a notification with the form's scheduling fields: the
+// scheduled time, total sends, repeat interval and mode, send once and send window.
+// An id the user can't view fails with storage.ErrNotFound.
+func (s *Server) scheduleFromForm(r *http.Request, settings model.Settings) (*model.Notification, error) {
+	var n *model.Notification
+	if id := r.FormValue("id"); id != "" {
+		// Other users' notifications are reported as missing rather than forbidden
+		current, err := s.store.GetNotification(id)
+		if err != nil || !canView(r, current) {
+			return nil, storage.ErrNotFound
+		}
+		// Like an update, an unparseable time keeps the current one
+		updated := *current
+		n = &updated
+		if scheduledTime, err := s.parseScheduledTime(r.FormValue("datetime")); err == nil {
+			s.moveSchedule(n, scheduledTime)
+		}
+	} else {
+		scheduledTime, err := s.formScheduledTime(r)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkLeadTime(r, settings, scheduledTime); err != nil {
+			return nil, err
+		}
+		n = &model.Notification{ScheduledTime: scheduledTime, TotalSends: 3}
+	}
+
+	if totalSendsStr := formTotalSends(r); totalSendsStr != "" {
+		totalSends, err := s.parseTotalSends(totalSendsStr)
+		if err != nil {
+			return nil, err
+		}
+		n.TotalSends = totalSends
+	}
+	var err error
+	if n.RepeatInterval, err = formRepeatInterval(r); err != nil {
+		return nil, err
+	}
+	n.StopOnFirstDelivery = r.FormValue("send_once") == "on"
+	n.WeekdaysOnly = r.FormValue("weekdays_only") == "on"
+
+	if n.RepeatUntil, err = s.parseRepeatUntil(r, n.ScheduledTime); err != nil {
+		return nil, err
+	}
+	if n.SendWindowStart, n.SendWindowEnd, err = parseSendWindow(r.FormValue("send_window_start"), r.FormValue("send_window_end")); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
@@ -0,0 +1,47 @@
+package web
+
+import "net/http"
+
+// DefaultContentSecurityPolicy allows what the embedded UI needs: HTMX and Tailwind from
+// their CDNs, the inline scripts and event handlers in the templates (hx-on handlers
+// are compiled with Function, hence 'unsafe-eval'), inline label colors, and
+// same-origin requests for HTMX and server-sent events.
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://unpkg.com https://cdn.tailwindcss.com; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+// SetContentSecurityPolicy replaces the default Content-Security-Policy, e.g. to allow
+// self-hosted assets; "off" sends none
+func (s *Server) SetContentSecurityPolicy(policy string) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	switch policy {
+	case "":
+		s.csp = DefaultContentSecurityPolicy
+	case "off":
+		s.csp = ""
+	default:
+		s.csp = policy
+	}
+}
+
+// securityHeaders sets the browser hardening headers on every response. The referrer
+// policy keeps tokens in acknowledge and calendar URLs from leaking to other sites.
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		s.configMu.RLock()
+		csp := s.csp
+		s.configMu.RUnlock()
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "same-origin")
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,16 +2,31 @@ package web
 
 import (
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/noahxzhu/pushover-notify/internal/auth"
+	"github.com/noahxzhu/pushover-notify/internal/dateparse"
+	"github.com/noahxzhu/pushover-notify/internal/i18n"
 	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
 	"github.com/noahxzhu/pushover-notify/internal/storage"
 	"github.com/noahxzhu/pushover-notify/internal/worker"
 )
@@ -20,34 +35,233 @@ import (
 var templateFS embed.FS
 
 type Server struct {
-	store      *storage.Store
+	store      storage.Backend
 	router     *http.ServeMux
-	sessions   map[string]time.Time
+	handler    http.Handler // router wrapped in gzip compression
+	sessions   map[string]session
+	sessionsMu sync.Mutex
 	worker     *worker.Worker // Inject Worker to trigger Refresh
 	sseClients map[chan string]bool
 	sseMux     sync.Mutex
+	bootID     string // Distinguishes ETags across restarts, when the store version resets
+	buildInfo  BuildInfo
+	templates  fs.FS                   // Embedded by default; a directory on disk in dev mode
+	deleted    map[string]deletedEntry // Recently deleted notifications that can still be restored
+	deletedMu  sync.Mutex
+
+	soundPreviews   map[string]time.Time // Last sound preview per user, for rate limiting
+	soundPreviewsMu sync.Mutex
+
+	configMu         sync.RWMutex // Guards the settings a config reload can change: durations, maxTotalSends and csp
+	sessionDuration  time.Duration
+	rememberDuration time.Duration // Used when "remember me" is ticked at login
+	cookieSameSite   http.SameSite
+	trustProxy       bool // Trust X-Forwarded-Proto when deciding whether cookies are Secure
+
+	basePath string // Path prefix when mounted below the site root, e.g. "/reminders"; empty at root
+
+	precision     time.Duration // Scheduled times are truncated to this: a minute, or a second when keeping seconds
+	subMinute     bool          // The forms offer seconds
+	maxTotalSends int           // Upper bound on TotalSends accepted from forms
+
+	csp string // Content-Security-Policy header; empty sends none
+
+	maintenance atomic.Bool // Maintenance mode: data is read-only and the worker is paused
+	replies     atomic.Bool // Replies posted to /reply are accepted
+	standby     atomic.Bool // Another instance is the sender: data is read-only here as in maintenance mode
+}
+
+// BuildInfo identifies the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
 }
 
-func NewServer(store *storage.Store, w *worker.Worker) *Server {
+func NewServer(store storage.Backend, w *worker.Worker) *Server {
 	s := &Server{
 		store:      store,
 		router:     http.NewServeMux(),
-		sessions:   make(map[string]time.Time),
+		sessions:   make(map[string]session),
 		worker:     w,
 		sseClients: make(map[chan string]bool),
+		bootID:     uuid.New().String()[:8],
+		templates:  templateFS,
+		deleted:    make(map[string]deletedEntry),
+
+		soundPreviews: make(map[string]time.Time),
+
+		sessionDuration:  defaultSessionDuration,
+		rememberDuration: defaultRememberDuration,
+		cookieSameSite:   http.SameSiteLaxMode,
+		precision:        time.Minute,
+		maxTotalSends:    defaultMaxTotalSends,
+		csp:              DefaultContentSecurityPolicy,
 	}
 	s.routes()
+	s.handler = s.securityHeaders(gzipHandler(s.maintenanceGuard(s.router)))
 
 	// Register callback for worker updates
 	w.SetOnUpdate(s.broadcastRefresh)
+	w.SetOnTick(func() { s.broadcast("status", "tick") })
 
 	return s
 }
 
-// parseRepeatInterval extracts value and unit from interval string like "30m", "2h", "1d"
+var intervalPattern = regexp.MustCompile(`^(\d+)([smhd])$`)
+
+// SetBuildInfo sets the build details reported by /api/version
+func (s *Server) SetBuildInfo(info BuildInfo) {
+	s.buildInfo = info
+}
+
+// SetBasePath mounts the app below the site root, e.g. "/reminders" for https://myhost/reminders/.
+// Routes, redirects and template links are all prefixed with it.
+func (s *Server) SetBasePath(p string) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		s.basePath = ""
+		return
+	}
+	s.basePath = "/" + p
+}
+
+// SetSubMinute offers seconds in the forms: a seconds field on times and a seconds
+// unit for intervals. Times keep their seconds only with SetKeepSeconds.
+func (s *Server) SetSubMinute(enabled bool) {
+	s.subMinute = enabled
+}
+
+// SetKeepSeconds keeps the seconds of submitted times instead of truncating them to
+// the minute, whether they come from the forms, Quick Add or the API. The worker must
+// be set the same way, or it truncates them again when sending.
+func (s *Server) SetKeepSeconds(enabled bool) {
+	s.precision = time.Minute
+	if enabled {
+		s.precision = time.Second
+	}
+}
+
+// formTotalSends reads the total sends field, accepting the old "repeat_times"
+// name from scripts written before the rename
+func formTotalSends(r *http.Request) string {
+	if v := r.FormValue("total_sends"); v != "" {
+		return v
+	}
+	return r.FormValue("repeat_times")
+}
+
+// defaultMaxTotalSends bounds TotalSends unless configured otherwise
+const defaultMaxTotalSends = 100
+
+// SetMaxTotalSends sets the largest accepted TotalSends; 0 uses the default
+func (s *Server) SetMaxTotalSends(n int) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+	s.maxTotalSends = defaultMaxTotalSends
+	if n > 0 {
+		s.maxTotalSends = n
+	}
+}
+
+// parseTotalSends validates a submitted TotalSends, counted as the send mode says
+func (s *Server) parseTotalSends(raw string) (int, error) {
+	s.configMu.RLock()
+	limit := s.maxTotalSends
+	s.configMu.RUnlock()
+
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 1 || n > limit {
+		return 0, fmt.Errorf("Send count must be between 1 and %d", limit)
+	}
+	return n, nil
+}
+
+// formScheduledTime reads the add form's scheduled time: the datetime field, or with
+// schedule_mode=relative an offset from now, truncated to the scheduling precision
+func (s *Server) formScheduledTime(r *http.Request) (time.Time, error) {
+	if r.FormValue("schedule_mode") == "relative" {
+		// "In N minutes/hours/days" from now
+		offset, err := intervalDuration(r.FormValue("offset_value") + r.FormValue("offset_unit"))
+		if err != nil || offset <= 0 {
+			return time.Time{}, fmt.Errorf("Invalid relative offset")
+		}
+		return time.Now().Add(offset).Truncate(s.precision), nil
+	}
+	scheduledTime, err := s.parseScheduledTime(r.FormValue("datetime"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid date/time format. Error: %v", err)
+	}
+	return scheduledTime, nil
+}
+
+// parseScheduledTime parses a submitted date and time, truncated to the scheduling precision
+func (s *Server) parseScheduledTime(value string) (time.Time, error) {
+	t, err := parseDatetime(value)
+	return t.Truncate(s.precision), err
+}
+
+// moveSchedule applies an edited scheduled time to n. Moving it starts a pending
+// notification's series over from the new time; the sends already made stay in its
+// history. Seconds the edit form doesn't show aren't taken for a move.
+func (s *Server) moveSchedule(n *model.Notification, scheduled time.Time) {
+	resolution := time.Minute
+	if s.subMinute {
+		resolution = s.precision
+	}
+	if scheduled.Truncate(resolution).Equal(n.ScheduledTime.Truncate(resolution)) {
+		return
+	}
+	n.ScheduledTime = scheduled
+	if n.Status != model.StatusDone {
+		n.SendsCount = 0
+		n.RetryAt = time.Time{}
+		n.SnoozedUntil = time.Time{}
+	}
+}
+
+// Layouts accepted for submitted times. Browsers send datetime-local values with or
+// without seconds (Safari includes them, and milliseconds with a small step); scripts
+// may add a UTC offset or use a space instead of the "T".
+var (
+	zonedDatetimeLayouts = []string{"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04Z07:00"}
+	localDatetimeLayouts = []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+)
+
+// parseDatetime parses a date and time in any accepted layout. Times without an
+// offset are in the server's local time zone.
+func parseDatetime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range zonedDatetimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Local(), nil
+		}
+	}
+	for _, layout := range localDatetimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date and time like 2006-01-02T15:04, optionally with seconds and a UTC offset", value)
+}
+
+// path prefixes an app-absolute path like "/settings" with the base path
+func (s *Server) path(p string) string {
+	return s.basePath + p
+}
+
+// SetTemplateDir serves templates from dir on disk instead of the embedded copies.
+// dir must contain the same layout as internal/web, i.e. a templates/ subdirectory.
+// Templates are parsed on every request, so edits show up on refresh.
+func (s *Server) SetTemplateDir(dir string) {
+	if dir != "" {
+		s.templates = os.DirFS(dir)
+	}
+}
+
+// parseRepeatInterval extracts value and unit from interval string like "10s", "30m", "2h", "1d"
 func parseRepeatInterval(interval string) (value int, unit string) {
-	re := regexp.MustCompile(`^(\d+)([mhd])$`)
-	matches := re.FindStringSubmatch(interval)
+	matches := intervalPattern.FindStringSubmatch(interval)
 	if len(matches) == 3 {
 		fmt.Sscanf(matches[1], "%d", &value)
 		unit = matches[2]
@@ -57,6 +271,213 @@ func parseRepeatInterval(interval string) (value int, unit string) {
 	return 30, "m"
 }
 
+// intervalDuration converts an interval string like "10s", "30m", "2h", "1d" to a duration.
+// Unlike time.ParseDuration it understands days.
+func intervalDuration(interval string) (time.Duration, error) {
+	return model.ParseInterval(interval)
+}
+
+// splitDuration is the inverse of intervalDuration, using the largest unit that
+// divides d exactly; zero stays zero
+func splitDuration(d time.Duration) (value int, unit string) {
+	switch {
+	case d <= 0:
+		return 0, "d"
+	case d%(24*time.Hour) == 0:
+		return int(d / (24 * time.Hour)), "d"
+	case d%time.Hour == 0:
+		return int(d / time.Hour), "h"
+	case d%time.Minute == 0:
+		return int(d / time.Minute), "m"
+	default:
+		return int(d / time.Second), "s"
+	}
+}
+
+// parseAutoDelete reads the optional grace period after which a Done notification
+// is removed; an empty or zero value keeps it
+func parseAutoDelete(value, unit string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	d, err := intervalDuration(value + unit)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid auto-delete period")
+	}
+	return d, nil
+}
+
+// parseSendWindow reads the optional time-of-day window sends are limited to. Both
+// ends are needed; an end before the start spans midnight.
+func parseSendWindow(start, end string) (string, string, error) {
+	if start == "" && end == "" {
+		return "", "", nil
+	}
+	from, err1 := time.Parse("15:04", start)
+	to, err2 := time.Parse("15:04", end)
+	if err1 != nil || err2 != nil {
+		return "", "", fmt.Errorf("Invalid send window: give both start and end as HH:MM")
+	}
+	if from.Equal(to) {
+		return "", "", fmt.Errorf("Invalid send window: start and end must differ")
+	}
+	return start, end, nil
+}
+
+// maxInstanceNameLength bounds the instance name put in front of every message title
+const maxInstanceNameLength = 30
+
+// maxPlaceLength bounds a notification's place name, which becomes the map link's
+// title: Pushover allows 100 characters there
+const maxPlaceLength = 100
+
+// parseLocation reads an optional place name and "lat,long" coordinates, returning
+// the coordinates normalized to "lat,long" without spaces
+func parseLocation(place, coordinates string) (string, string, error) {
+	place = strings.TrimSpace(place)
+	if len(place) > maxPlaceLength {
+		return "", "", fmt.Errorf("Invalid place: at most %d characters", maxPlaceLength)
+	}
+	coordinates = strings.TrimSpace(coordinates)
+	if coordinates == "" {
+		return place, "", nil
+	}
+
+	latStr, lngStr, ok := strings.Cut(coordinates, ",")
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+	if !ok || err1 != nil || err2 != nil {
+		return "", "", fmt.Errorf("Invalid coordinates: use latitude,longitude, e.g. 48.8584,2.2945")
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return "", "", fmt.Errorf("Invalid coordinates: latitude must be between -90 and 90 and longitude between -180 and 180")
+	}
+	return place, strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lng, 'f', -1, 64), nil
+}
+
+// checkContent validates message content, filling in its values first when it is
+// a template, so a template that won't render is rejected on save rather than at
+// send time
+func checkContent(content string, values map[string]string) error {
+	rendered, err := model.RenderContent(content, values)
+	if err != nil {
+		return err
+	}
+	return pushover.ValidateHTML(rendered)
+}
+
+// formContent reads and validates the content and template values fields
+func formContent(r *http.Request) (string, map[string]string, error) {
+	content := r.FormValue("content")
+	values, err := model.ParseValues(r.FormValue("values"))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := checkContent(content, values); err != nil {
+		return "", nil, err
+	}
+	return content, values, nil
+}
+
+// parseImageURL validates an optional image URL for message attachments. Hosts that
+// are plainly local, such as localhost or a private IP address, are refused here; the
+// worker refuses names that resolve to one when it fetches the image.
+func parseImageURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Invalid image URL: must be an http(s) URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	addr, err := netip.ParseAddr(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || (err == nil && !worker.IsPublicAddr(addr)) {
+		return "", fmt.Errorf("Invalid image URL: must be on a public address")
+	}
+	return raw, nil
+}
+
+// parseWebhookURL validates an optional http(s) webhook URL
+func parseWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Invalid webhook URL: must be an http(s) URL")
+	}
+	return raw, nil
+}
+
+// maxHolidays bounds the holiday list, a year's worth of days
+const maxHolidays = 366
+
+// parseHolidays reads dates as YYYY-MM-DD, separated by commas or whitespace, and
+// returns them sorted without duplicates
+func parseHolidays(raw string) ([]string, error) {
+	var holidays []string
+	for _, field := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		if _, err := time.Parse("2006-01-02", field); err != nil {
+			return nil, fmt.Errorf("Invalid holiday %q: use YYYY-MM-DD", field)
+		}
+		holidays = append(holidays, field)
+	}
+	slices.Sort(holidays)
+	holidays = slices.Compact(holidays)
+	if len(holidays) > maxHolidays {
+		return nil, fmt.Errorf("Too many holidays (max %d)", maxHolidays)
+	}
+	return holidays, nil
+}
+
+// parsePriority reads a Pushover priority, defaulting to normal
+func parsePriority(raw string) (int, error) {
+	if raw == "" {
+		return pushover.PriorityNormal, nil
+	}
+	p, err := strconv.Atoi(raw)
+	if err != nil || p < pushover.PriorityLowest || p > pushover.PriorityEmergency {
+		return 0, fmt.Errorf("Invalid priority: must be between -2 and 2")
+	}
+	return p, nil
+}
+
+// parseEscalation reads a comma-separated list of per-send priorities, e.g. "0, 0, 2"
+func parseEscalation(raw string) ([]int, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var steps []int
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		p, err := parsePriority(part)
+		if part == "" || err != nil {
+			return nil, fmt.Errorf("Invalid escalation: use priorities between -2 and 2 separated by commas")
+		}
+		steps = append(steps, p)
+	}
+	return steps, nil
+}
+
+// parseRepeatUntil reads the end time of an until-mode repeat series, or returns the
+// zero time when the form repeats a fixed number of times
+func (s *Server) parseRepeatUntil(r *http.Request, scheduled time.Time) (time.Time, error) {
+	if r.FormValue("repeat_mode") != "until" {
+		return time.Time{}, nil
+	}
+	until, err := s.parseScheduledTime(r.FormValue("repeat_until"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid repeat until time")
+	}
+	if !until.After(scheduled) {
+		return time.Time{}, fmt.Errorf("Repeat until must be after the scheduled time")
+	}
+	return until, nil
+}
+
 // combineRepeatInterval combines value and unit into interval string
 func combineRepeatInterval(value string, unit string) string {
 	if value == "" {
@@ -68,139 +489,175 @@ func combineRepeatInterval(value string, unit string) string {
 	return value + unit
 }
 
+// formRepeatInterval reads the repeat interval fields, 30 minutes when left empty. An
+// interval must be a positive number of seconds, minutes, hours or days.
+func formRepeatInterval(r *http.Request) (string, error) {
+	interval := combineRepeatInterval(r.FormValue("repeat_interval_value"), r.FormValue("repeat_interval_unit"))
+	if d, err := intervalDuration(interval); err != nil || d <= 0 {
+		return "", fmt.Errorf("Repeat interval must be a positive number of seconds, minutes, hours or days")
+	}
+	return interval, nil
+}
+
 func (s *Server) routes() {
 	// Public routes
 	s.router.HandleFunc("/login", s.handleLogin)
 	s.router.HandleFunc("/setup", s.handleSetup)
+	s.router.HandleFunc("/ack/", s.handleAck)                           // The ack token itself is the credential
+	s.router.HandleFunc("/pushover/callback", s.handlePushoverCallback) // The receipt is the credential
+	s.router.HandleFunc("/reply", s.handleReply)                        // The reply token is the credential
+	s.router.HandleFunc("/static/", s.handleStatic)
+	s.router.HandleFunc("/favicon.ico", s.handleFavicon)
+	s.router.HandleFunc("/calendar.ics", s.handleCalendar) // Authenticated by its own token
 
 	// Protected routes
 	s.router.HandleFunc("/", s.authMiddleware(s.handleIndex))
-	s.router.HandleFunc("/settings", s.authMiddleware(s.handleSettings))
+	s.router.HandleFunc("/settings", s.adminMiddleware(s.handleSettings))
+	s.router.HandleFunc("/settings/users", s.adminMiddleware(s.handleAddUser))
+	s.router.HandleFunc("/settings/users/delete", s.adminMiddleware(s.handleDeleteUser))
+	s.router.HandleFunc("/settings/labels", s.adminMiddleware(s.handleAddLabel))
+	s.router.HandleFunc("/settings/labels/delete", s.adminMiddleware(s.handleDeleteLabel))
+	s.router.HandleFunc("/audit", s.adminMiddleware(s.handleAudit))
 	s.router.HandleFunc("/logout", s.handleLogout)
-
-	// HTMX API routes
-	s.router.HandleFunc("/api/notifications", s.authMiddleware(s.handleAPINotifications))
+	s.router.HandleFunc("/calendar/token", s.authMiddleware(s.handleCalendarToken))
+	s.router.HandleFunc("/api-key", s.authMiddleware(s.handleAPIKey))
+	s.router.HandleFunc("/language", s.authMiddleware(s.handleLanguage))
+	s.router.HandleFunc("/api/list-order", s.authMiddleware(s.handleListOrder))
+
+	// HTMX API routes; anything that mutates requires a role that can write
+	s.router.HandleFunc("/api/notifications", s.writerMiddleware(s.handleAPINotifications))
+	s.router.HandleFunc("/api/notifications/bulk", s.writerMiddleware(s.handleAPIBulkAdd))
+	s.router.HandleFunc("/api/notifications/bulk-done", s.writerMiddleware(s.handleAPIBulkDone))
+	s.router.HandleFunc("/api/notifications/preview", s.writerMiddleware(s.handleAPIPreviewNotification))
+	s.router.HandleFunc("/api/notifications/schedule", s.writerMiddleware(s.handleAPIScheduleNotification))
 	s.router.HandleFunc("/api/notifications/", s.authMiddleware(s.handleAPINotificationByID))
+	s.router.HandleFunc("/api/groups/", s.writerMiddleware(s.handleAPIGroup))
 	s.router.HandleFunc("/api/notifications-list", s.authMiddleware(s.handleAPINotificationsList))
+	s.router.HandleFunc("/api/quick-add", s.writerMiddleware(s.handleAPIQuickAdd))
+	s.router.HandleFunc("/api/mute", s.authMiddleware(s.handleAPIMute))
+	s.router.HandleFunc("/api/worker-status", s.authMiddleware(s.handleAPIWorkerStatus))
+	s.router.HandleFunc("/api/storage-status", s.authMiddleware(s.handleAPIStorageStatus))
+	s.router.HandleFunc("/api/send-stats", s.authMiddleware(s.handleAPISendStats))
+	s.router.HandleFunc("/api/setup-status", s.authMiddleware(s.handleAPISetupStatus))
+	s.router.HandleFunc("/api/version", s.authMiddleware(s.handleAPIVersion))
+	s.router.HandleFunc("/api/maintenance", s.adminMiddleware(s.handleAPIMaintenance))
+	s.router.HandleFunc("/api/sound-preview", s.adminMiddleware(s.handleAPISoundPreview))
+	s.router.HandleFunc("/api/integrity", s.adminMiddleware(s.handleAPIIntegrity))
 	s.router.HandleFunc("/api/events", s.authMiddleware(s.handleSSE))
-}
 
-func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.router.ServeHTTP(w, r)
+	// External API, authenticated by API key rather than session
+	s.router.HandleFunc("/api/v1/summary", s.apiKeyMiddleware(s.handleAPISummary))
+	s.router.HandleFunc("/api/v1/notifications", s.apiKeyMiddleware(s.handleAPICreateNotification))
 }
 
-// Middleware
-func (s *Server) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		settings := s.store.GetSettings()
+// maxRequestBodyBytes bounds form submissions; every form in the UI is far smaller
+const maxRequestBodyBytes = 64 << 10 // 64 KB
 
-		if settings.Password == "" {
-			http.Redirect(w, r, "/setup", http.StatusSeeOther)
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Bound and parse request bodies up front. FormValue swallows parse errors,
+	// so an oversized body would otherwise look like an empty form.
+	switch r.Method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		if err := r.ParseForm(); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid form data", 400)
 			return
 		}
+	}
 
-		cookie, err := r.Cookie("session_token")
-		if err != nil || cookie.Value == "" {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+	if s.basePath != "" {
+		if r.URL.Path == s.basePath {
+			http.Redirect(w, r, s.path("/"), http.StatusMovedPermanently)
 			return
 		}
-
-		expiry, ok := s.sessions[cookie.Value]
-		if !ok || time.Now().After(expiry) {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		rest, ok := strings.CutPrefix(r.URL.Path, s.basePath)
+		if !ok || !strings.HasPrefix(rest, "/") {
+			http.NotFound(w, r)
 			return
 		}
+		// Route on the path below the prefix, like http.StripPrefix
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		r = r2
+	}
+
+	s.handler.ServeHTTP(w, r)
+}
 
-		next(w, r)
+// allowMethods reports whether r uses one of methods; otherwise it replies
+// 405 with an Allow header. HEAD is accepted wherever GET is.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m || (m == "GET" && r.Method == "HEAD") {
+			return true
+		}
 	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "Method not allowed", 405)
+	return false
 }
 
 // Handlers
 
-func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
-	settings := s.store.GetSettings()
-	if settings.Password != "" {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
 		return
 	}
 
-	if r.Method == "GET" {
-		s.renderTemplate(w, "setup.html", nil)
+	token := strings.TrimPrefix(r.URL.Path, "/ack/")
+	n, err := s.store.AcknowledgeNotification(token)
+	if err != nil {
+		http.Error(w, "Invalid or expired acknowledge link", 404)
 		return
 	}
 
-	if r.Method == "POST" {
-		password := r.FormValue("password")
-		if password == "" {
-			http.Error(w, "Password is required", 400)
-			return
-		}
-
-		settings.Password = password
-		if settings.RepeatInterval == "" {
-			settings.RepeatInterval = "30m"
-			settings.RepeatTimes = 3
-		}
-
-		if err := s.store.UpdateSettings(settings); err != nil {
-			http.Error(w, "Failed to save settings", 500)
-			return
-		}
-
-		s.worker.Refresh() // Trigger worker update
+	s.worker.Refresh()
+	s.broadcastRefresh()
 
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-	}
+	s.renderTemplate(w, r, "ack.html", n)
 }
 
-func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	settings := s.store.GetSettings()
-	if settings.Password == "" {
-		http.Redirect(w, r, "/setup", http.StatusSeeOther)
+// handlePushoverCallback receives Pushover's callback for an acknowledged emergency
+// message and marks the notification it was sent for as Done
+func (s *Server) handlePushoverCallback(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
 		return
 	}
-
-	if r.Method == "GET" {
-		s.renderTemplate(w, "login.html", nil)
+	if r.FormValue("acknowledged") != "1" {
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	if r.Method == "POST" {
-		password := r.FormValue("password")
-		if password != settings.Password {
-			s.renderTemplate(w, "login.html", map[string]interface{}{"Error": "Invalid password"})
-			return
-		}
-
-		sessionToken := uuid.New().String()
-		s.sessions[sessionToken] = time.Now().Add(24 * time.Hour)
-
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session_token",
-			Value:    sessionToken,
-			Expires:  time.Now().Add(24 * time.Hour),
-			HttpOnly: true,
-		})
-
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	at := time.Now()
+	if unix, err := strconv.ParseInt(r.FormValue("acknowledged_at"), 10, 64); err == nil && unix > 0 {
+		at = time.Unix(unix, 0)
 	}
-}
-
-func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
-	cookie, _ := r.Cookie("session_token")
-	if cookie != nil {
-		delete(s.sessions, cookie.Value)
+	n, err := s.store.AcknowledgeReceipt(r.FormValue("receipt"), at)
+	if err != nil {
+		http.Error(w, "Unknown receipt", 404)
+		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HttpOnly: true,
-	})
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	slog.Info("Emergency notification acknowledged", "id", n.ID, "by", r.FormValue("acknowledged_by_device"))
+
+	s.worker.Refresh()
+	s.broadcastRefresh()
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
 	if r.Method == "GET" {
 		settings := s.store.GetSettings()
 		value, unit := parseRepeatInterval(settings.RepeatInterval)
@@ -208,12 +665,16 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 			model.Settings
 			RepeatIntervalValue int
 			RepeatIntervalUnit  string
+			CurrentUser         *model.User
+			Sounds              []string
 		}{
-			Settings:           settings,
+			Settings:            settings,
 			RepeatIntervalValue: value,
 			RepeatIntervalUnit:  unit,
+			CurrentUser:         currentUser(r),
+			Sounds:              pushover.Sounds,
 		}
-		s.renderTemplate(w, "settings.html", data)
+		s.renderTemplate(w, r, "settings.html", data)
 		return
 	}
 
@@ -221,52 +682,330 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		settings := s.store.GetSettings()
 		settings.PushoverToken = r.FormValue("pushover_token")
 		settings.PushoverUser = r.FormValue("pushover_user")
-		settings.RepeatInterval = combineRepeatInterval(r.FormValue("repeat_interval_value"), r.FormValue("repeat_interval_unit"))
-		fmt.Sscanf(r.FormValue("repeat_times"), "%d", &settings.RepeatTimes)
-
-		newPass := r.FormValue("new_password")
-		if newPass != "" {
-			settings.Password = newPass
+		settings.DefaultTitle = strings.TrimSpace(r.FormValue("default_title"))
+		if settings.DefaultTitle == "" {
+			settings.DefaultTitle = "Reminder"
+		}
+		settings.InstanceName = strings.TrimSpace(r.FormValue("instance_name"))
+		if len(settings.InstanceName) > maxInstanceNameLength {
+			http.Error(w, fmt.Sprintf("Instance name is too long (max %d characters)", maxInstanceNameLength), 400)
+			return
+		}
+		totalSends, err := s.parseTotalSends(formTotalSends(r))
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		settings.TotalSends = totalSends
+		if settings.RepeatInterval, err = formRepeatInterval(r); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if settings.FallbackWebhookURL, err = parseWebhookURL(r.FormValue("fallback_webhook_url")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if settings.Holidays, err = parseHolidays(r.FormValue("holidays")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		settings.SendMode = model.SendModeTotal
+		if r.FormValue("send_mode") == model.SendModeRepeats {
+			settings.SendMode = model.SendModeRepeats
+		}
+		switch dedupe := r.FormValue("dedupe_mode"); dedupe {
+		case model.DedupeOff, model.DedupeReject, model.DedupeMerge:
+			settings.DedupeMode = dedupe
+		default:
+			http.Error(w, "Invalid duplicate handling", 400)
+			return
+		}
+		if sound := r.FormValue("sound"); pushover.ValidSound(sound) {
+			settings.Sound = sound
+		} else {
+			http.Error(w, "Unknown sound", 400)
+			return
+		}
+		fmt.Sscanf(r.FormValue("jitter_seconds"), "%d", &settings.JitterSeconds)
+		settings.JitterSeconds = max(0, min(settings.JitterSeconds, 60))
+		fmt.Sscanf(r.FormValue("min_lead_minutes"), "%d", &settings.MinLeadMinutes)
+		settings.MinLeadMinutes = max(0, min(settings.MinLeadMinutes, maxLeadMinutes))
+
+		// Password changes apply to the signed-in user and sign out their other sessions
+		var changedUser *model.User
+		if newPass := r.FormValue("new_password"); newPass != "" {
+			hash, err := auth.HashPassword(newPass)
+			if err != nil {
+				http.Error(w, "Failed to hash password", 500)
+				return
+			}
+			users := append([]model.User{}, settings.Users...)
+			for i := range users {
+				if users[i].ID == currentUser(r).ID {
+					users[i].PasswordHash = hash
+					users[i].SessionVersion++
+					changedUser = &users[i]
+				}
+			}
+			settings.Users = users
 		}
 
-		if err := s.store.UpdateSettings(settings); err != nil {
+		// A failed write still leaves the new settings in memory, and new credentials must
+		// wake a worker holding notifications back for them, so refresh either way
+		err = s.store.UpdateSettings(settings)
+		s.worker.Refresh()
+		if err != nil {
 			http.Error(w, "Failed to update settings", 500)
 			return
 		}
 
-		s.worker.Refresh() // Trigger worker update
+		if changedUser != nil {
+			s.resetUserSessions(w, r, changedUser)
+		}
 
-		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		http.Redirect(w, r, s.path("/settings"), http.StatusSeeOther)
 	}
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	notifs := s.store.GetAllNotifications()
+	// "/" is the catch-all pattern; don't render the index for unknown paths
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
 	settings := s.store.GetSettings()
 	intervalValue, intervalUnit := parseRepeatInterval(settings.RepeatInterval)
 
 	data := struct {
-		Notifications      []*model.Notification
-		Defaults           model.Settings
+		Notifications       []notificationView
+		CurrentUser         *model.User
+		Defaults            model.Settings
 		RepeatIntervalValue int
 		RepeatIntervalUnit  string
+		Mute                muteStatus
+		WorkerStatus        worker.Status
+		SendStats           sendStats
+		Storage             storage.Health
+		Setup               setupStatus
+		CalendarURL         string
+		APIKey              string
+		Locales             []i18n.Locale
 	}{
-		Notifications:      notifs,
-		Defaults:           settings,
+		Notifications:       s.visibleNotifications(r),
+		CurrentUser:         currentUser(r),
+		Defaults:            settings,
 		RepeatIntervalValue: intervalValue,
 		RepeatIntervalUnit:  intervalUnit,
+		Mute:                s.currentMute(r),
+		WorkerStatus:        s.worker.Status(),
+		SendStats:           s.currentSendStats(r),
+		Storage:             s.store.Health(),
+		Setup:               s.currentSetup(r),
+		CalendarURL:         s.calendarURL(r, currentUser(r)),
+		APIKey:              currentUser(r).APIKey,
+		Locales:             i18n.Locales,
 	}
-	s.renderTemplate(w, "index.html", data)
+	s.renderTemplate(w, r, "index.html", data)
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	events, err := s.store.GetAuditLog(500)
+	if err != nil {
+		http.Error(w, "Failed to read audit log: "+err.Error(), 500)
+		return
+	}
+	data := struct {
+		Events      []model.AuditEvent
+		CurrentUser *model.User
+	}{
+		Events:      events,
+		CurrentUser: currentUser(r),
+	}
+	s.renderTemplate(w, r, "audit.html", data)
 }
 
 // HTMX API Handlers
 
+func (s *Server) handleAPIWorkerStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	s.renderPartial(w, r, "worker_status", s.worker.Status())
+}
+
+func (s *Server) handleAPIStorageStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	s.renderPartial(w, r, "storage_banner", s.store.Health())
+}
+
+// setupStatus is shown in the setup banner while Pushover credentials are missing
+type setupStatus struct {
+	Missing bool
+	Waiting int // The user's pending notifications, none of which can be sent
+	Due     int // Of those, the ones already past their send time
+	IsAdmin bool
+}
+
+// currentSetup reports whether notifications the current user can see are waiting
+// for Pushover credentials
+func (s *Server) currentSetup(r *http.Request) setupStatus {
+	settings := s.store.GetSettings()
+	if settings.PushoverToken != "" && settings.PushoverUser != "" {
+		return setupStatus{}
+	}
+
+	status := setupStatus{Missing: true, IsAdmin: isAdmin(r)}
+	now := time.Now()
+	for _, n := range s.store.GetPending() {
+		if !canView(r, n) {
+			continue
+		}
+		status.Waiting++
+		if !n.Paused && !now.Before(s.worker.NextSendTime(n, settings)) {
+			status.Due++
+		}
+	}
+	return status
+}
+
+func (s *Server) handleAPISetupStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	s.renderPartial(w, r, "setup_banner", s.currentSetup(r))
+}
+
+func (s *Server) handleAPIVersion(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.buildInfo)
+}
+
+// muteStatus is the view model for the mute banner
+type muteStatus struct {
+	Active    bool
+	Until     time.Time
+	Remaining string // e.g. "1h20m"
+	CanEdit   bool
+}
+
+func (s *Server) currentMute(r *http.Request) muteStatus {
+	until := s.store.GetSettings().MutedUntil
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		return muteStatus{CanEdit: isAdmin(r) && !s.readOnly()}
+	}
+	// Round up so the banner never shows "0m" while still muted
+	text := strings.TrimSuffix((remaining + time.Minute - 1).Truncate(time.Minute).String(), "0s")
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return muteStatus{Active: true, Until: until, Remaining: text, CanEdit: isAdmin(r) && !s.readOnly()}
+}
+
+// handleAPIMute renders the mute banner (GET) or sets/clears the global mute (POST).
+// POST accepts duration=30m|2h|tomorrow|off.
+func (s *Server) handleAPIMute(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET", "POST") {
+		return
+	}
+
+	if r.Method == "POST" {
+		if !isAdmin(r) {
+			http.Error(w, tr(r, "error.read_only"), http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		var until time.Time
+		switch d := r.FormValue("duration"); d {
+		case "off":
+			// Zero time clears the mute
+		case "tomorrow":
+			tomorrow := now.AddDate(0, 0, 1)
+			until = time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 8, 0, 0, 0, now.Location())
+		default:
+			offset, err := intervalDuration(d)
+			if err != nil || offset <= 0 {
+				http.Error(w, "Invalid mute duration", 400)
+				return
+			}
+			until = now.Add(offset)
+		}
+
+		settings := s.store.GetSettings()
+		settings.MutedUntil = until
+		if err := s.store.UpdateSettings(settings); err != nil {
+			http.Error(w, "Failed to update settings", 500)
+			return
+		}
+
+		s.worker.Refresh()
+		s.broadcast("mute", "changed")
+	}
+
+	s.renderPartial(w, r, "mute_banner", s.currentMute(r))
+}
+
 func (s *Server) handleAPINotificationsList(w http.ResponseWriter, r *http.Request) {
-	notifs := s.store.GetAllNotifications()
-	s.renderPartial(w, "notifications_list", notifs)
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	etag := s.listETag(r)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	s.renderNotificationsList(w, r)
+}
+
+// listETag is the ETag of the notifications list as rendered for r. The version is
+// read before the data, so a concurrent change can only make it stale, never wrong.
+// The list is filtered per user, translated into the request's locale and drawn
+// without editing controls while read-only, so all three are part of the tag. It is
+// weak because the same tag covers the gzipped and the plain body.
+func (s *Server) listETag(r *http.Request) string {
+	return fmt.Sprintf(`W/"%s-%d-%s-%s-%t"`, s.bootID, s.store.Version(), currentUser(r).ID, requestLocale(r), s.readOnly())
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, comparing weakly
+// as If-None-Match does: a W/ prefix on either side is ignored
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -289,9 +1028,17 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "SSE not supported", http.StatusInternalServerError)
 		return
 	}
-
-	// Send initial connection event
-	fmt.Fprintf(w, "event: connected\ndata: ok\n\n")
+	// The stream stays open until the client leaves, so lift the server's write timeout.
+	// Should that fail, the stream ends at the timeout and the browser reconnects.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	// Send initial connection event. A client coming back sends the ID of the last event
+	// it saw; it only needs a refresh if the data has changed since.
+	current := s.eventID()
+	fmt.Fprintf(w, "id: %s\nevent: connected\ndata: ok\n\n", current)
+	if last := lastEventID(r); last != "" && last != current {
+		fmt.Fprintf(w, "id: %s\nevent: refresh\ndata: notifications\n\n", current)
+	}
 	flusher.Flush()
 
 	for {
@@ -306,72 +1053,251 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) broadcastRefresh() {
+	s.broadcast("refresh", "notifications")
+}
+
+// eventID identifies the data as of an SSE event: the store version, which every
+// change advances, qualified by the boot ID since the version restarts with the process
+func (s *Server) eventID() string {
+	return fmt.Sprintf("%s-%d", s.bootID, s.store.Version())
+}
+
+// lastEventID is the ID of the last event a reconnecting client saw, from the
+// Last-Event-ID header or, for a client opening a new EventSource, the last_event_id
+// parameter
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("last_event_id")
+}
+
+// broadcast sends an SSE event to every connected client
+func (s *Server) broadcast(event, data string) {
 	s.sseMux.Lock()
 	defer s.sseMux.Unlock()
 
+	msg := fmt.Sprintf("id: %s\nevent: %s\ndata: %s", s.eventID(), event, data)
 	for clientChan := range s.sseClients {
 		select {
-		case clientChan <- "event: refresh\ndata: notifications":
+		case clientChan <- msg:
 		default:
 			// Client buffer full, skip
 		}
 	}
 }
 
+// notificationView is a notification as rendered for the current user
+type notificationView struct {
+	*model.Notification
+	CanEdit bool
+	Label   *model.Label
+	Group   *groupView // Set on the first listed member of a group
+}
+
+// visibleNotifications returns the notifications the current user may see, pinned
+// ones first and each group in the user's list order, with notification groups kept
+// together
+func (s *Server) visibleNotifications(r *http.Request) []notificationView {
+	settings := s.store.GetSettings()
+	var views []notificationView
+	for _, n := range s.store.GetAllNotifications() {
+		if canView(r, n) {
+			views = append(views, notificationView{Notification: n, CanEdit: canManage(r, n) && !s.readOnly(), Label: findLabel(settings.Labels, n.LabelID)})
+		}
+	}
+	s.sortNotifications(views, currentUser(r).ListOrder, settings)
+	return groupNotifications(views)
+}
+
+// renderNotificationsList renders the full list partial for the current user
+func (s *Server) renderNotificationsList(w http.ResponseWriter, r *http.Request) {
+	s.renderPartial(w, r, "notifications_list", s.visibleNotifications(r))
+}
+
 func (s *Server) handleAPINotifications(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", 405)
+	if !allowMethods(w, r, "POST") {
 		return
 	}
 
-	datetimeStr := r.FormValue("datetime")
-	content := r.FormValue("content")
-
-	layout := "2006-01-02T15:04"
+	content, values, err := formContent(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
-	scheduledTime, err := time.ParseInLocation(layout, datetimeStr, time.Local)
+	scheduledTime, err := s.formScheduledTime(r)
 	if err != nil {
-		http.Error(w, "Invalid date/time format. Error: "+err.Error(), 400)
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := s.checkLeadTime(r, s.store.GetSettings(), scheduledTime); err != nil {
+		http.Error(w, err.Error(), 400)
 		return
 	}
-	// Truncate to minute (ensure seconds = 0)
-	scheduledTime = scheduledTime.Truncate(time.Minute)
 
 	n := &model.Notification{
 		ID:            uuid.New().String(),
 		Content:       content,
+		Values:        values,
 		ScheduledTime: scheduledTime,
 		Status:        model.StatusPending,
 		SendsCount:    0,
+		OwnerID:       currentUser(r).ID,
 	}
 
 	// Parse optional overrides
-	if repeatTimesStr := r.FormValue("repeat_times"); repeatTimesStr != "" {
-		fmt.Sscanf(repeatTimesStr, "%d", &n.RepeatTimes)
+	n.TotalSends = 3 // Fallback when not given
+	if totalSendsStr := formTotalSends(r); totalSendsStr != "" {
+		totalSends, err := s.parseTotalSends(totalSendsStr)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		n.TotalSends = totalSends
 	}
-	if n.RepeatTimes == 0 {
-		n.RepeatTimes = 3 // Fallback if 0 or parse fail
+
+	if n.RepeatInterval, err = formRepeatInterval(r); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
 	}
 
-	intervalValue := r.FormValue("repeat_interval_value")
-	intervalUnit := r.FormValue("repeat_interval_unit")
-	n.RepeatInterval = combineRepeatInterval(intervalValue, intervalUnit)
+	if r.FormValue("require_ack") == "on" {
+		n.AckToken = uuid.New().String()
+	}
+	n.StopOnFirstDelivery = r.FormValue("send_once") == "on"
+	n.WeekdaysOnly = r.FormValue("weekdays_only") == "on"
+	n.SendOnStartup = r.FormValue("send_on_startup") == "on"
+	imageURL, err := parseImageURL(r.FormValue("image_url"))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	n.ImageURL = imageURL
+	if n.RepeatUntil, err = s.parseRepeatUntil(r, n.ScheduledTime); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Priority, err = parsePriority(r.FormValue("priority")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Escalation, err = parseEscalation(r.FormValue("escalation")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.AutoDeleteAfter, err = parseAutoDelete(r.FormValue("auto_delete_value"), r.FormValue("auto_delete_unit")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.SendWindowStart, n.SendWindowEnd, err = parseSendWindow(r.FormValue("send_window_start"), r.FormValue("send_window_end")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Place, n.Coordinates, err = parseLocation(r.FormValue("place"), r.FormValue("coordinates")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.LabelID, err = s.parseLabelID(r.FormValue("label_id")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
-	if err := s.store.AddNotification(n); err != nil {
-		http.Error(w, "Failed to save: "+err.Error(), 500)
+	if err := s.addNotification(w, r, n); err != nil {
+		http.Error(w, "Failed to save: "+err.Error(), addErrorStatus(err))
 		return
 	}
 
+	// Return the full notifications list
+	s.renderNotificationsList(w, r)
+}
+
+// addErrorStatus is the HTTP status for a failed add: 429 when the pending cap was
+// hit and 409 for a rejected duplicate or a taken ID, so scripts can tell them apart
+// from a storage failure
+func addErrorStatus(err error) int {
+	if errors.Is(err, storage.ErrTooManyPending) {
+		return http.StatusTooManyRequests
+	}
+	if errors.Is(err, storage.ErrIDExists) {
+		return http.StatusConflict
+	}
+	var dup *storage.DuplicateError
+	if errors.As(err, &dup) {
+		return http.StatusConflict
+	}
+	return 500
+}
+
+// addNotification stores n and notifies the worker and connected clients. The
+// X-Notification-ID response header gets the ID stored, or the one it duplicates.
+func (s *Server) addNotification(w http.ResponseWriter, r *http.Request, n *model.Notification) error {
+	if err := s.store.AddNotification(n, actor(r)); err != nil {
+		var dup *storage.DuplicateError
+		if errors.As(err, &dup) {
+			w.Header().Set("X-Notification-ID", dup.ExistingID)
+		}
+		return err
+	}
+	w.Header().Set("X-Notification-ID", n.ID)
+
 	s.worker.Refresh() // Trigger worker update
 	s.broadcastRefresh()
+	return nil
+}
 
-	// Return the full notifications list
-	notifs := s.store.GetAllNotifications()
-	s.renderPartial(w, "notifications_list", notifs)
+// handleAPIQuickAdd creates a notification from a phrase like "tomorrow 9am buy milk".
+// With preview=1 it only renders the parsed interpretation for confirmation.
+func (s *Server) handleAPIQuickAdd(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	text := r.FormValue("text")
+	settings := s.store.GetSettings()
+	parsed, err := dateparse.Parse(text, time.Now())
+	if err == nil {
+		err = checkContent(parsed.Content, nil)
+	}
+	if err == nil {
+		err = s.checkLeadTime(r, settings, parsed.Time.Truncate(s.precision))
+	}
+	if err != nil {
+		// Show the problem in the preview area even when the form targeted the list
+		w.Header().Set("HX-Retarget", "#quick-add-preview")
+		w.Header().Set("HX-Reswap", "innerHTML")
+		s.renderPartial(w, r, "quick_add_preview", map[string]interface{}{"Error": err.Error(), "Text": text})
+		return
+	}
+
+	n := &model.Notification{
+		ID:             uuid.New().String(),
+		Content:        parsed.Content,
+		ScheduledTime:  parsed.Time.Truncate(s.precision),
+		Status:         model.StatusPending,
+		TotalSends:     settings.TotalSends,
+		RepeatInterval: settings.RepeatInterval,
+		OwnerID:        currentUser(r).ID,
+	}
+
+	if r.FormValue("preview") == "1" {
+		s.renderPartial(w, r, "quick_add_preview", map[string]interface{}{"Notification": n, "Text": text})
+		return
+	}
+
+	if err := s.addNotification(w, r, n); err != nil {
+		http.Error(w, "Failed to save: "+err.Error(), addErrorStatus(err))
+		return
+	}
+
+	// Clear the preview; the new row arrives through the SSE refresh
+	w.Header().Set("HX-Trigger", "quickAdded")
+	s.renderPartial(w, r, "notification_row", notificationView{Notification: n, CanEdit: true})
 }
 
 func (s *Server) handleAPINotificationByID(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from path: /api/notifications/{id} or /api/notifications/{id}/edit
+	// Extract ID from path: /api/notifications/{id} or /api/notifications/{id}/edit.
+	// Viewers may GET the details; everything else needs write access.
 	path := strings.TrimPrefix(r.URL.Path, "/api/notifications/")
 	parts := strings.Split(path, "/")
 	id := parts[0]
@@ -381,112 +1307,397 @@ func (s *Server) handleAPINotificationByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Check if this is an edit or delete-confirm request
-	if len(parts) > 1 && parts[1] == "edit" {
-		s.handleAPIGetEditForm(w, r, id)
+	if len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if readOnly := len(parts) == 1 && r.Method == "GET"; !readOnly && !canWrite(r) {
+		http.Error(w, tr(r, "error.read_only"), http.StatusForbidden)
 		return
 	}
-	if len(parts) > 1 && parts[1] == "delete-confirm" {
-		s.handleAPIGetDeleteConfirm(w, r, id)
+
+	// The notification no longer exists in the store, so undo does its own access check
+	if len(parts) == 2 && parts[1] == "undo-delete" {
+		if allowMethods(w, r, "POST") {
+			s.handleAPIUndoDelete(w, r, id)
+		}
 		return
 	}
 
+	// Other users' notifications are reported as missing rather than forbidden
+	if n, err := s.store.GetNotification(id); err != nil || !canView(r, n) {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	}
+
+	// Check if this is an edit or delete-confirm request
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "edit":
+			if allowMethods(w, r, "GET") {
+				s.handleAPIGetEditForm(w, r, id)
+			}
+		case "delete-confirm":
+			if allowMethods(w, r, "GET") {
+				s.handleAPIGetDeleteConfirm(w, r, id)
+			}
+		case "pin":
+			if allowMethods(w, r, "POST") {
+				s.handleAPITogglePin(w, r, id)
+			}
+		case "rearm":
+			if allowMethods(w, r, "POST") {
+				s.handleAPIRearm(w, r, id)
+			}
+		case "curl":
+			if allowMethods(w, r, "GET") {
+				s.handleAPIGetCurl(w, r, id)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+
+	if !allowMethods(w, r, "GET", "PUT", "DELETE") {
+		return
+	}
 	switch r.Method {
+	case "GET":
+		s.handleAPIGetDetail(w, r, id)
 	case "PUT":
 		s.handleAPIUpdateNotification(w, r, id)
 	case "DELETE":
 		s.handleAPIDeleteNotification(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", 405)
 	}
 }
 
 func (s *Server) handleAPIGetEditForm(w http.ResponseWriter, r *http.Request, id string) {
 	n, err := s.store.GetNotification(id)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		http.Error(w, tr(r, "error.not_found"), 404)
 		return
 	}
 
 	value, unit := parseRepeatInterval(n.RepeatInterval)
+	autoDeleteValue, autoDeleteUnit := splitDuration(n.AutoDeleteAfter)
 	data := struct {
 		*model.Notification
 		RepeatIntervalValue int
 		RepeatIntervalUnit  string
+		AutoDeleteValue     int
+		AutoDeleteUnit      string
+		Labels              []model.Label
+		SendMode            string
 	}{
 		Notification:       n,
 		RepeatIntervalValue: value,
 		RepeatIntervalUnit:  unit,
+		AutoDeleteValue:     autoDeleteValue,
+		AutoDeleteUnit:      autoDeleteUnit,
+		Labels:              s.store.GetSettings().Labels,
+		SendMode:            s.store.GetSettings().SendMode,
 	}
-	s.renderPartial(w, "edit_modal", data)
+	s.renderPartial(w, r, "edit_modal", data)
 }
 
 func (s *Server) handleAPIGetDeleteConfirm(w http.ResponseWriter, r *http.Request, id string) {
 	n, err := s.store.GetNotification(id)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		http.Error(w, tr(r, "error.not_found"), 404)
 		return
 	}
-	s.renderPartial(w, "delete_modal", n)
+	s.renderPartial(w, r, "delete_modal", n)
 }
 
+// handleAPIUpdateNotification applies the edit form. Moving the scheduled time starts
+// the series over from the new time; other edits keep the sends already made, so
+// changing the interval moves only the sends still to come and a count at or below
+// the sends made finishes the notification.
 func (s *Server) handleAPIUpdateNotification(w http.ResponseWriter, r *http.Request, id string) {
-	n, err := s.store.GetNotification(id)
+	current, err := s.store.GetNotification(id)
 	if err != nil {
-		http.Error(w, "Not found", 404)
+		http.Error(w, tr(r, "error.not_found"), 404)
 		return
 	}
+	// The form is checked against a copy, so a rejected form leaves the stored
+	// notification as it was
+	edited := *current
+	n := &edited
 
 	// Update fields
 	datetimeStr := r.FormValue("datetime")
-	content := r.FormValue("content")
-	repeatTimesStr := r.FormValue("repeat_times")
-	intervalValue := r.FormValue("repeat_interval_value")
-	intervalUnit := r.FormValue("repeat_interval_unit")
+	totalSendsStr := formTotalSends(r)
+
+	// Validate before changing anything; an empty value keeps the current count
+	content, values, err := formContent(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	totalSends := n.TotalSends
+	if totalSendsStr != "" {
+		if totalSends, err = s.parseTotalSends(totalSendsStr); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+	}
+	interval, err := formRepeatInterval(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
-	layout := "2006-01-02T15:04"
-	if scheduledTime, err := time.ParseInLocation(layout, datetimeStr, time.Local); err == nil {
-		n.ScheduledTime = scheduledTime.Truncate(time.Minute)
+	if scheduledTime, err := s.parseScheduledTime(datetimeStr); err == nil {
+		s.moveSchedule(n, scheduledTime)
 	}
 
 	n.Content = content
+	n.Values = values
 
-	var repeatTimes int
-	if _, err := fmt.Sscanf(repeatTimesStr, "%d", &repeatTimes); err == nil && repeatTimes > 0 {
-		n.RepeatTimes = repeatTimes
-	}
+	n.TotalSends = totalSends
+
+	n.RepeatInterval = interval
 
-	n.RepeatInterval = combineRepeatInterval(intervalValue, intervalUnit)
+	if r.FormValue("require_ack") == "on" {
+		if n.AckToken == "" {
+			n.AckToken = uuid.New().String()
+		}
+	} else {
+		n.AckToken = ""
+	}
+	n.StopOnFirstDelivery = r.FormValue("send_once") == "on"
+	n.WeekdaysOnly = r.FormValue("weekdays_only") == "on"
+	n.SendOnStartup = r.FormValue("send_on_startup") == "on"
+	imageURL, err := parseImageURL(r.FormValue("image_url"))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	n.ImageURL = imageURL
+	if n.RepeatUntil, err = s.parseRepeatUntil(r, n.ScheduledTime); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Priority, err = parsePriority(r.FormValue("priority")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Escalation, err = parseEscalation(r.FormValue("escalation")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.AutoDeleteAfter, err = parseAutoDelete(r.FormValue("auto_delete_value"), r.FormValue("auto_delete_unit")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.SendWindowStart, n.SendWindowEnd, err = parseSendWindow(r.FormValue("send_window_start"), r.FormValue("send_window_end")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.Place, n.Coordinates, err = parseLocation(r.FormValue("place"), r.FormValue("coordinates")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if n.LabelID, err = s.parseLabelID(r.FormValue("label_id")); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 
-	if err := s.store.UpdateNotification(n); err != nil {
+	// The worker may have sent it since, so the edit is applied to the notification
+	// as it now stands
+	err = s.store.WithTransaction(func(tx *storage.Tx) error {
+		current, err := tx.GetNotification(id)
+		if err != nil {
+			return err
+		}
+		updated := *current
+		s.applyEdit(&updated, &edited)
+		n = &updated
+		return tx.UpdateNotification(n, actor(r))
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to update", 500)
 		return
 	}
+	// The edit is saved either way; a failed correction shows in the audit log
+	if r.FormValue("notify_on_edit") == "on" && n.SendsCount > 0 {
+		if err := s.worker.SendCorrection(n, actor(r)); err != nil {
+			slog.Error("Failed to send correction", "id", n.ID, "error", err)
+		}
+	}
 
 	s.worker.Refresh()
 	s.broadcastRefresh()
 
 	// Return updated list
-	notifs := s.store.GetAllNotifications()
-	s.renderPartial(w, "notifications_list", notifs)
+	s.renderNotificationsList(w, r)
+}
+
+// applyEdit copies the fields the edit form sets from edited to n, moving n's
+// schedule if the scheduled time changed. The rest of n, such as the sends made,
+// stays as it is.
+func (s *Server) applyEdit(n, edited *model.Notification) {
+	s.moveSchedule(n, edited.ScheduledTime)
+	n.Content = edited.Content
+	n.Values = edited.Values
+	n.TotalSends = edited.TotalSends
+	n.RepeatInterval = edited.RepeatInterval
+	n.AckToken = edited.AckToken
+	n.StopOnFirstDelivery = edited.StopOnFirstDelivery
+	n.WeekdaysOnly = edited.WeekdaysOnly
+	n.SendOnStartup = edited.SendOnStartup
+	n.ImageURL = edited.ImageURL
+	n.RepeatUntil = edited.RepeatUntil
+	n.Priority = edited.Priority
+	n.Escalation = edited.Escalation
+	n.AutoDeleteAfter = edited.AutoDeleteAfter
+	n.SendWindowStart, n.SendWindowEnd = edited.SendWindowStart, edited.SendWindowEnd
+	n.Place, n.Coordinates = edited.Place, edited.Coordinates
+	n.LabelID = edited.LabelID
+}
+
+// handleAPITogglePin pins or unpins a notification and returns the re-sorted list
+func (s *Server) handleAPITogglePin(w http.ResponseWriter, r *http.Request, id string) {
+	err := s.store.WithTransaction(func(tx *storage.Tx) error {
+		n, err := tx.GetNotification(id)
+		if err != nil {
+			return err
+		}
+		updated := *n
+		updated.Pinned = !n.Pinned
+		return tx.UpdateNotification(&updated, actor(r))
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to update", 500)
+		return
+	}
+
+	s.broadcastRefresh()
+	s.renderNotificationsList(w, r)
+}
+
+// errNotDone is returned when re-arming a notification that is still pending
+var errNotDone = errors.New("Only Done notifications can be re-armed")
+
+// handleAPIRearm resets a Done notification to Pending so its series runs again,
+// at the submitted datetime if any. A scheduled time already past moves to now.
+func (s *Server) handleAPIRearm(w http.ResponseWriter, r *http.Request, id string) {
+	var scheduled time.Time
+	if datetimeStr := r.FormValue("datetime"); datetimeStr != "" {
+		var err error
+		if scheduled, err = s.parseScheduledTime(datetimeStr); err != nil {
+			http.Error(w, "Invalid date/time format. Error: "+err.Error(), 400)
+			return
+		}
+	}
+
+	err := s.store.WithTransaction(func(tx *storage.Tx) error {
+		n, err := tx.GetNotification(id)
+		if err != nil {
+			return err
+		}
+		if n.Status != model.StatusDone {
+			return errNotDone
+		}
+		updated := *n
+		if !scheduled.IsZero() {
+			updated.ScheduledTime = scheduled
+		} else if now := time.Now().Truncate(s.precision); updated.ScheduledTime.Before(now) {
+			updated.ScheduledTime = now
+		}
+		updated.Status = model.StatusPending
+		updated.SendsCount = 0
+		updated.LastError = ""
+		updated.RetryAt = time.Time{}
+		updated.AcknowledgedAt = time.Time{}
+		if updated.AckToken != "" {
+			updated.AckToken = uuid.New().String() // Links from the last run mustn't acknowledge this one
+		}
+		if updated.ReplyToken != "" {
+			updated.ReplyToken = uuid.New().String() // Nor replies to its messages
+		}
+		updated.SnoozedUntil = time.Time{}
+		updated.Receipts = nil // Nor may its emergency receipts
+		return tx.UpdateNotification(&updated, actor(r))
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		http.Error(w, tr(r, "error.not_found"), 404)
+		return
+	case errors.Is(err, errNotDone):
+		http.Error(w, err.Error(), 409)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update: "+err.Error(), addErrorStatus(err))
+		return
+	}
+
+	s.worker.Refresh()
+	s.broadcastRefresh()
+	s.renderNotificationsList(w, r)
 }
 
 func (s *Server) handleAPIDeleteNotification(w http.ResponseWriter, r *http.Request, id string) {
-	if err := s.store.DeleteNotification(id); err != nil {
+	n, err := s.store.GetNotification(id)
+	if err != nil {
+		http.Error(w, "Notification not found", 404)
+		return
+	}
+	deleted := *n // Copy before the store drops it
+
+	if err := s.store.DeleteNotification(id, actor(r)); err != nil {
 		http.Error(w, "Failed to delete: "+err.Error(), 500)
 		return
 	}
+	s.rememberDeleted(deleted)
 
 	s.worker.Refresh()
 	s.broadcastRefresh()
 
+	w.Header().Set("HX-Trigger", undoTrigger(deleted))
+
 	// Return updated list
-	notifs := s.store.GetAllNotifications()
-	s.renderPartial(w, "notifications_list", notifs)
+	s.renderNotificationsList(w, r)
+}
+
+// templateFuncs are available to every template. t, formatTime, datetime and number
+// follow the request's locale; {{path "/settings"}} builds a link under the base path,
+// {{static "favicon.svg"}} a versioned asset URL, and {{if subMinute}} guards
+// second-granularity inputs.
+func (s *Server) templateFuncs(r *http.Request) template.FuncMap {
+	locale := requestLocale(r)
+	return template.FuncMap{
+		"t":          func(key string, args ...any) string { return i18n.T(locale, key, args...) },
+		"locale":     func() string { return locale },
+		"formatTime": func(style string, t time.Time) string { return i18n.FormatTime(locale, style, t) },
+		// datetime shows seconds only when scheduled times keep them
+		"datetime": func(t time.Time) string {
+			if s.precision < time.Minute {
+				return i18n.FormatTime(locale, "datetime_seconds", t)
+			}
+			return i18n.FormatTime(locale, "datetime", t)
+		},
+		"number":      func(n int) string { return i18n.FormatInt(locale, n) },
+		"path":        s.path,
+		"static":      s.staticURL,
+		"subMinute":   func() bool { return s.subMinute },
+		"maintenance": s.maintenance.Load,
+		"standby":     s.standby.Load,
+		"readOnly":    s.readOnly,
+		"eventID":     s.eventID,
+	}
 }
 
-func (s *Server) renderTemplate(w http.ResponseWriter, tmplName string, data interface{}) {
-	tmpl, err := template.ParseFS(templateFS, "templates/"+tmplName, "templates/layouts/*.html", "templates/partials/*.html")
+func (s *Server) renderTemplate(w http.ResponseWriter, r *http.Request, tmplName string, data interface{}) {
+	tmpl, err := template.New(tmplName).Funcs(s.templateFuncs(r)).ParseFS(s.templates, "templates/"+tmplName, "templates/layouts/*.html", "templates/partials/*.html")
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template error: %v", err), 500)
 		return
@@ -496,8 +1707,8 @@ func (s *Server) renderTemplate(w http.ResponseWriter, tmplName string, data int
 	}
 }
 
-func (s *Server) renderPartial(w http.ResponseWriter, partialName string, data interface{}) {
-	tmpl, err := template.ParseFS(templateFS, "templates/partials/*.html")
+func (s *Server) renderPartial(w http.ResponseWriter, r *http.Request, partialName string, data interface{}) {
+	tmpl, err := template.New("partials").Funcs(s.templateFuncs(r)).ParseFS(s.templates, "templates/partials/*.html")
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template error: %v", err), 500)
 		return
@@ -0,0 +1,449 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+	"github.com/noahxzhu/pushover-notify/internal/worker"
+)
+
+// testServer is a Server on an in-memory store with one signed-in admin
+type testServer struct {
+	*Server
+	store   storage.Backend
+	worker  *worker.Worker
+	user    model.User
+	session string // Value of the admin's session cookie
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	store := storage.NewInMemoryStore()
+	user := model.User{ID: "admin-id", Username: "admin", Role: model.RoleAdmin}
+	settings := store.GetSettings()
+	settings.Users = []model.User{user}
+	if err := store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	w := worker.NewWorker(store)
+	s := NewServer(store, w)
+	session, _ := s.createSession(&user, time.Hour)
+	return &testServer{Server: s, store: store, worker: w, user: user, session: session}
+}
+
+// do sends a request as the signed-in admin; form, if not nil, is posted url-encoded
+func (ts *testServer) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	r := httptest.NewRequest(method, path, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: ts.session})
+	rec := httptest.NewRecorder()
+	ts.ServeHTTP(rec, r)
+	return rec
+}
+
+// addNotification stores n, failing the test on error
+func (ts *testServer) addNotification(t *testing.T, n *model.Notification) {
+	t.Helper()
+	if n.OwnerID == "" {
+		n.OwnerID = ts.user.ID
+	}
+	if err := ts.store.AddNotification(n, "test"); err != nil {
+		t.Fatalf("AddNotification: %v", err)
+	}
+}
+
+func TestParseDatetime(t *testing.T) {
+	saved := time.Local
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+	t.Cleanup(func() { time.Local = saved })
+
+	tests := []struct {
+		value string
+		want  string // In UTC; empty when the value is rejected
+	}{
+		{"2030-01-02T10:00", "2030-01-02 08:00:00"},
+		{"2030-01-02T10:00:30", "2030-01-02 08:00:30"},
+		{"2030-01-02T10:00:30.500", "2030-01-02 08:00:30.5"},
+		{"2030-01-02 10:00", "2030-01-02 08:00:00"},
+		{"2030-01-02 10:00:30", "2030-01-02 08:00:30"},
+		{"  2030-01-02T10:00  ", "2030-01-02 08:00:00"},
+		{"2030-01-02T10:00Z", "2030-01-02 10:00:00"},
+		{"2030-01-02T10:00+05:30", "2030-01-02 04:30:00"},
+		{"2030-01-02T10:00:30Z", "2030-01-02 10:00:30"},
+		{"2030-01-02T10:00:30-08:00", "2030-01-02 18:00:30"},
+		{"", ""},
+		{"tomorrow", ""},
+		{"2030-01-02", ""},
+		{"10:00", ""},
+		{"02/01/2030 10:00", ""},
+		{"2030-13-02T10:00", ""},
+		{"2030-02-30T10:00", ""},
+		{"2030-01-02T25:00", ""},
+		{"2030-01-02T10:00+25:00", ""},
+		{"2030-01-02T10", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseDatetime(tt.value)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseDatetime(%q) = %s, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDatetime(%q): %v", tt.value, err)
+			continue
+		}
+		if s := got.UTC().Format("2006-01-02 15:04:05.999"); s != tt.want {
+			t.Errorf("parseDatetime(%q) = %s UTC, want %s", tt.value, s, tt.want)
+		}
+		if got.Location() != time.Local {
+			t.Errorf("parseDatetime(%q) is in %s, want the server's time zone", tt.value, got.Location())
+		}
+	}
+}
+
+func TestParseImageURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"", false},
+		{"https://example.com/cat.png", false},
+		{"http://93.184.216.34/cat.png", false},
+		{"ftp://example.com/cat.png", true},
+		{"http://localhost:8080/cat.png", true},
+		{"http://LOCALHOST./cat.png", true},
+		{"http://app.localhost/cat.png", true},
+		{"http://127.0.0.1/cat.png", true},
+		{"http://10.0.0.5/cat.png", true},
+		{"http://169.254.169.254/latest/meta-data/", true},
+		{"http://[::1]:8080/cat.png", true},
+		{"http://[::ffff:192.168.1.1]/cat.png", true},
+	}
+	for _, tt := range tests {
+		if _, err := parseImageURL(tt.raw); (err != nil) != tt.wantErr {
+			t.Errorf("parseImageURL(%q) error = %v, want error %v", tt.raw, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeepSeconds(t *testing.T) {
+	const submitted = "2030-01-02T10:00:30"
+	tests := []struct {
+		keepSeconds bool
+		wantSecond  int
+	}{
+		{false, 0},
+		{true, 30},
+	}
+	for _, tt := range tests {
+		ts := newTestServer(t)
+		ts.SetKeepSeconds(tt.keepSeconds)
+		ts.worker.SetKeepSeconds(tt.keepSeconds)
+
+		single, err := ts.parseScheduledTime(submitted)
+		if err != nil {
+			t.Fatalf("parseScheduledTime: %v", err)
+		}
+		bulk, errs := ts.parseBulkLines(submitted+" | Stand up", ts.store.GetSettings(), ts.user.ID, func(time.Time) error { return nil })
+		if len(errs) > 0 || len(bulk) != 1 {
+			t.Fatalf("parseBulkLines: %v", errs)
+		}
+
+		for name, scheduled := range map[string]time.Time{"single": single, "bulk": bulk[0].ScheduledTime} {
+			if got := scheduled.Second(); got != tt.wantSecond {
+				t.Errorf("keep_seconds=%v, %s add: second = %d, want %d", tt.keepSeconds, name, got, tt.wantSecond)
+			}
+			// The worker must not truncate again: every send lands on the same second
+			n := &model.Notification{ID: "n", ScheduledTime: scheduled, TotalSends: 3, RepeatInterval: "1h"}
+			for i, send := range ts.worker.Schedule(n, model.Settings{}, 3) {
+				if send.Second() != tt.wantSecond {
+					t.Errorf("keep_seconds=%v, %s add: send %d at %s, want second %d", tt.keepSeconds, name, i, send.Format("15:04:05"), tt.wantSecond)
+				}
+			}
+		}
+	}
+}
+
+// settingsForm is a valid settings form as the settings page submits it, with the
+// current credentials and defaults
+func (ts *testServer) settingsForm() url.Values {
+	settings := ts.store.GetSettings()
+	return url.Values{
+		"pushover_token":        {settings.PushoverToken},
+		"pushover_user":         {settings.PushoverUser},
+		"default_title":         {settings.DefaultTitle},
+		"repeat_interval_value": {"30"},
+		"repeat_interval_unit":  {"m"},
+		"total_sends":           {"3"},
+		"send_mode":             {model.SendModeTotal},
+	}
+}
+
+func TestAddFormValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		totalSends string
+		value      string
+		unit       string
+		wantStatus int
+	}{
+		{"smallest count", "1", "30", "m", http.StatusOK},
+		{"largest count", "100", "30", "m", http.StatusOK},
+		{"count zero", "0", "30", "m", http.StatusBadRequest},
+		{"count negative", "-1", "30", "m", http.StatusBadRequest},
+		{"count over the maximum", "101", "30", "m", http.StatusBadRequest},
+		{"count not a number", "three", "30", "m", http.StatusBadRequest},
+		{"shortest interval", "3", "1", "s", http.StatusOK},
+		{"interval in days", "3", "7", "d", http.StatusOK},
+		{"interval left empty", "3", "", "", http.StatusOK},
+		{"interval zero", "3", "0", "m", http.StatusBadRequest},
+		{"interval negative", "3", "-5", "m", http.StatusBadRequest},
+		{"interval not a number", "3", "soon", "m", http.StatusBadRequest},
+		{"interval unknown unit", "3", "2", "w", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			form := url.Values{
+				"content":               {"Stretch"},
+				"datetime":              {time.Now().Add(time.Hour).Format("2006-01-02T15:04")},
+				"total_sends":           {tt.totalSends},
+				"repeat_interval_value": {tt.value},
+				"repeat_interval_unit":  {tt.unit},
+			}
+			rec := ts.do("POST", "/api/notifications", form)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d (%s), want %d", rec.Code, strings.TrimSpace(rec.Body.String()), tt.wantStatus)
+			}
+			if added := len(ts.store.GetAllNotifications()); (added == 1) != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("%d notifications stored", added)
+			}
+		})
+	}
+}
+
+func TestSettingsFormValidation(t *testing.T) {
+	tests := []struct {
+		field, value string
+		wantStatus   int
+	}{
+		{"total_sends", "1", http.StatusSeeOther},
+		{"total_sends", "0", http.StatusBadRequest},
+		{"total_sends", "-3", http.StatusBadRequest},
+		{"total_sends", "101", http.StatusBadRequest},
+		{"repeat_interval_value", "0", http.StatusBadRequest},
+		{"repeat_interval_value", "-30", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		ts := newTestServer(t)
+		form := ts.settingsForm()
+		form.Set(tt.field, tt.value)
+		if rec := ts.do("POST", "/settings", form); rec.Code != tt.wantStatus {
+			t.Errorf("%s=%s: status = %d, want %d", tt.field, tt.value, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+// editForm is the edit form for n as the edit dialog submits it unchanged
+func editForm(n *model.Notification) url.Values {
+	value, unit := parseRepeatInterval(n.RepeatInterval)
+	return url.Values{
+		"content":               {n.Content},
+		"datetime":              {n.ScheduledTime.Format("2006-01-02T15:04")},
+		"total_sends":           {strconv.Itoa(n.TotalSends)},
+		"repeat_interval_value": {strconv.Itoa(value)},
+		"repeat_interval_unit":  {unit},
+	}
+}
+
+func TestEditRules(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	// Scheduled three hours ago, sent twice so far, the last time ten minutes ago
+	sentTwice := func() *model.Notification {
+		return &model.Notification{
+			ID:             "edited",
+			Content:        "Check the oven",
+			Status:         model.StatusPending,
+			ScheduledTime:  now.Add(-3 * time.Hour),
+			TotalSends:     5,
+			RepeatInterval: "2h",
+			SendsCount:     2,
+			LastPushTime:   now.Add(-10 * time.Minute),
+			History: []model.SendAttempt{
+				{Time: now.Add(-3 * time.Hour), OK: true},
+				{Time: now.Add(-10 * time.Minute), OK: true},
+			},
+		}
+	}
+	// edit submits the edit form for a fresh copy of sentTwice, changed by change
+	edit := func(t *testing.T, change func(url.Values)) (*testServer, *model.Notification) {
+		t.Helper()
+		ts := newTestServer(t)
+		n := sentTwice()
+		ts.addNotification(t, n)
+		form := editForm(n)
+		change(form)
+		if rec := ts.do("PUT", "/api/notifications/"+n.ID, form); rec.Code != http.StatusOK {
+			t.Fatalf("edit: status = %d (%s)", rec.Code, strings.TrimSpace(rec.Body.String()))
+		}
+		edited, err := ts.store.GetNotification(n.ID)
+		if err != nil {
+			t.Fatalf("GetNotification: %v", err)
+		}
+		return ts, edited
+	}
+	// sendNow records a successful send of n at now, as the worker would
+	sendNow := func(n *model.Notification) {
+		n.SendsCount++
+		n.LastPushTime = now
+		n.History = append(n.History, model.SendAttempt{Time: now, OK: true})
+	}
+
+	t.Run("content only keeps progress", func(t *testing.T) {
+		ts, n := edit(t, func(form url.Values) { form.Set("content", "Check the oven again") })
+		if n.Content != "Check the oven again" {
+			t.Errorf("content = %q, not edited", n.Content)
+		}
+		if n.SendsCount != 2 || len(n.History) != 2 || !n.ScheduledTime.Equal(now.Add(-3*time.Hour)) {
+			t.Errorf("progress changed: SendsCount = %d, %d attempts, scheduled %s", n.SendsCount, len(n.History), n.ScheduledTime)
+		}
+		// Send 3 still follows send 2 at the interval
+		if next, want := ts.worker.NextSendTime(n, model.Settings{}), now.Add(110*time.Minute); !next.Equal(want) {
+			t.Errorf("next send at %s, want %s", next, want)
+		}
+	})
+
+	t.Run("moving the schedule starts the series over", func(t *testing.T) {
+		moved := now.Add(24 * time.Hour)
+		ts, n := edit(t, func(form url.Values) { form.Set("datetime", moved.Format("2006-01-02T15:04")) })
+		if n.SendsCount != 0 || !n.ScheduledTime.Equal(moved) {
+			t.Errorf("after the move: SendsCount = %d, scheduled %s; want 0, %s", n.SendsCount, n.ScheduledTime, moved)
+		}
+		if len(n.History) != 2 {
+			t.Errorf("%d attempts in the history, want the 2 made before the move", len(n.History))
+		}
+		if next := ts.worker.NextSendTime(n, model.Settings{}); !next.Equal(moved) {
+			t.Errorf("next send at %s, want the new time %s", next, moved)
+		}
+		if got := len(ts.worker.Schedule(n, model.Settings{}, 10)); got != 5 {
+			t.Errorf("%d sends to come, want all 5", got)
+		}
+	})
+
+	t.Run("overdue repeats are spaced", func(t *testing.T) {
+		// At 30 minutes, sends 3 and 4 are already past; they follow the last send at
+		// the interval instead of going out together
+		ts, n := edit(t, func(form url.Values) {
+			form.Set("repeat_interval_value", "30")
+			form.Set("repeat_interval_unit", "m")
+		})
+		if n.SendsCount != 2 {
+			t.Fatalf("SendsCount = %d after an interval change, want 2", n.SendsCount)
+		}
+		if next, want := ts.worker.NextSendTime(n, model.Settings{}), now.Add(20*time.Minute); !next.Equal(want) {
+			t.Errorf("send 3 at %s, want %s", next, want)
+		}
+		sendNow(n)
+		if next, want := ts.worker.NextSendTime(n, model.Settings{}), now.Add(30*time.Minute); !next.Equal(want) {
+			t.Errorf("send 4 at %s, want an interval after send 3, %s", next, want)
+		}
+	})
+
+	t.Run("schedule moved into the past sends once, then spaces repeats", func(t *testing.T) {
+		moved := now.Add(-5 * time.Hour)
+		ts, n := edit(t, func(form url.Values) { form.Set("datetime", moved.Format("2006-01-02T15:04")) })
+		if next := ts.worker.NextSendTime(n, model.Settings{}); next.After(now) {
+			t.Errorf("first send at %s, want it due now", next)
+		}
+		sendNow(n)
+		if next, want := ts.worker.NextSendTime(n, model.Settings{}), now.Add(2*time.Hour); !next.Equal(want) {
+			t.Errorf("second send at %s, want an interval after the first, %s", next, want)
+		}
+	})
+}
+
+func TestSchedulePreviewHidesOtherUsers(t *testing.T) {
+	ts := newTestServer(t)
+	other := model.User{ID: "user-id", Username: "sam", Role: model.RoleUser}
+	settings := ts.store.GetSettings()
+	settings.Users = append(settings.Users, other)
+	if err := ts.store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	scheduled := time.Now().Add(time.Hour).Truncate(time.Minute)
+	ts.addNotification(t, &model.Notification{ID: "admins", Content: "Board meeting", ScheduledTime: scheduled, TotalSends: 3, RepeatInterval: "1h"})
+	form := url.Values{"id": {"admins"}, "datetime": {scheduled.Format("2006-01-02T15:04")}, "total_sends": {"3"}}
+
+	if rec := ts.do("POST", "/api/notifications/schedule", form); rec.Code != http.StatusOK {
+		t.Fatalf("owner: status = %d, want 200", rec.Code)
+	}
+	ts.session, _ = ts.createSession(&other, time.Hour)
+	if rec := ts.do("POST", "/api/notifications/schedule", form); rec.Code != http.StatusNotFound {
+		t.Errorf("other user: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestNotificationsListETag(t *testing.T) {
+	ts := newTestServer(t)
+	ts.addNotification(t, &model.Notification{ID: "n1", Content: "Listed", Status: model.StatusPending, ScheduledTime: time.Now().Add(time.Hour)})
+
+	get := func(acceptLanguage, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/notifications-list", nil)
+		r.AddCookie(&http.Cookie{Name: "session_token", Value: ts.session})
+		r.Header.Set("Accept-Language", acceptLanguage)
+		r.Header.Set("Accept-Encoding", "gzip")
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		ts.ServeHTTP(rec, r)
+		return rec
+	}
+
+	first := get("en", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q", first.Code, etag)
+	}
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("ETag %s is strong, but covers the gzipped body too", etag)
+	}
+	if rec := get("en", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("same request: status = %d, want 304", rec.Code)
+	}
+	// Clients may send the tag back without the weak marker
+	if rec := get("en", strings.TrimPrefix(etag, "W/")); rec.Code != http.StatusNotModified {
+		t.Errorf("strong form of the tag: status = %d, want 304", rec.Code)
+	}
+
+	if rec := get("de", etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("other locale: status = %d, ETag %s; want 200 and a new tag", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	ts.SetMaintenance(true)
+	readOnly := get("en", etag)
+	if readOnly.Code != http.StatusOK || readOnly.Header().Get("ETag") == etag {
+		t.Errorf("read-only: status = %d, ETag %s; want 200 and a new tag", readOnly.Code, readOnly.Header().Get("ETag"))
+	}
+	ts.SetMaintenance(false)
+	if rec := get("en", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("back from maintenance: status = %d, want 304", rec.Code)
+	}
+}
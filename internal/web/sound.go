@@ -0,0 +1,70 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
+	"github.com/noahxzhu/pushover-notify/internal/worker"
+)
+
+// soundPreviewInterval is how long a user waits between sound previews, each of which
+// is a real message counting against the Pushover quota
+const soundPreviewInterval = 10 * time.Second
+
+// handleAPISoundPreview sends a short test message with the chosen sound so it can be
+// heard before saving it. Outcomes, errors included, are rendered into the settings form.
+func (s *Server) handleAPISoundPreview(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "POST") {
+		return
+	}
+
+	sound := r.FormValue("sound")
+	data := map[string]interface{}{"Sound": sound}
+	if !pushover.ValidSound(sound) {
+		data["Error"] = "Unknown sound"
+		s.renderPartial(w, r, "sound_preview", data)
+		return
+	}
+	if wait := s.reserveSoundPreview(currentUser(r).ID); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+1)))
+		data["Error"] = fmt.Sprintf("Please wait %ds before another preview", int(wait.Seconds()+1))
+		s.renderPartial(w, r, "sound_preview", data)
+		return
+	}
+
+	msg := pushover.Message{Title: "Sound preview", Message: "This is the " + soundName(sound) + " sound", Sound: sound}
+	if err := s.worker.SendDirect(msg); err != nil {
+		if errors.Is(err, worker.ErrNoCredentials) {
+			data["Error"] = "Save your Pushover token and user key first"
+		} else {
+			data["Error"] = "Failed to send: " + pushover.ErrorMessage(err)
+		}
+	}
+	s.renderPartial(w, r, "sound_preview", data)
+}
+
+// reserveSoundPreview records a preview for userID, or returns how long until the
+// next one is allowed
+func (s *Server) reserveSoundPreview(userID string) time.Duration {
+	s.soundPreviewsMu.Lock()
+	defer s.soundPreviewsMu.Unlock()
+
+	now := time.Now()
+	if wait := s.soundPreviews[userID].Add(soundPreviewInterval).Sub(now); wait > 0 {
+		return wait
+	}
+	s.soundPreviews[userID] = now
+	return 0
+}
+
+// soundName is how a sound is called in messages and the settings form
+func soundName(sound string) string {
+	if sound == "" {
+		return "device's default"
+	}
+	return `"` + sound + `"`
+}
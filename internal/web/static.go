@@ -0,0 +1,66 @@
+package web
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+//go:embed static
+var staticFS embed.FS
+
+// staticVersion changes whenever an embedded asset does. Asset URLs carry it as a query
+// string, which is what makes the year-long immutable caching safe.
+var staticVersion = func() string {
+	h := sha256.New()
+	fs.WalkDir(staticFS, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := staticFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(name))
+		h.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}()
+
+// staticURL returns the versioned URL of an embedded asset, for use in templates
+func (s *Server) staticURL(name string) string {
+	return s.path("/static/" + name + "?v=" + staticVersion)
+}
+
+// handleStatic serves embedded assets under /static/ with long-lived caching
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+	s.serveAsset(w, r, strings.TrimPrefix(r.URL.Path, "/static/"), "public, max-age=31536000, immutable")
+}
+
+// handleFavicon serves the icon at the path browsers request by default. The URL isn't
+// versioned, so it is cached for a day rather than forever.
+func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	s.serveAsset(w, r, "favicon.svg", "public, max-age=86400")
+}
+
+func (s *Server) serveAsset(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	// Only files are served: no directory listings, and missing files aren't cached
+	info, err := fs.Stat(staticFS, "static/"+name)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeFileFS(w, r, staticFS, "static/"+name)
+}
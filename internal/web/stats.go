@@ -0,0 +1,31 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// sendStats is the send summary shown on the index page
+type sendStats struct {
+	Day  storage.SendCounts
+	Week storage.SendCounts
+}
+
+// currentSendStats counts the sends of the notifications the current user can see
+// over the last day and week
+func (s *Server) currentSendStats(r *http.Request) sendStats {
+	match := func(n *model.Notification) bool { return canView(r, n) }
+	counts := s.store.SendStats(time.Now(), match, 24*time.Hour, 7*24*time.Hour)
+	return sendStats{Day: counts[0], Week: counts[1]}
+}
+
+func (s *Server) handleAPISendStats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, "GET") {
+		return
+	}
+
+	s.renderPartial(w, r, "send_stats", s.currentSendStats(r))
+}
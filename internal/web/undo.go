@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+const (
+	undoWindow     = 30 * time.Second
+	maxUndoEntries = 20 // Oldest entries are dropped beyond this
+)
+
+type deletedEntry struct {
+	n       model.Notification
+	expires time.Time
+}
+
+// rememberDeleted keeps a copy of n so the deletion can be undone within undoWindow
+func (s *Server) rememberDeleted(n model.Notification) {
+	s.deletedMu.Lock()
+	defer s.deletedMu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for id, e := range s.deleted {
+		if now.After(e.expires) {
+			delete(s.deleted, id)
+			continue
+		}
+		if oldest == "" || e.expires.Before(s.deleted[oldest].expires) {
+			oldest = id
+		}
+	}
+	if len(s.deleted) >= maxUndoEntries {
+		delete(s.deleted, oldest)
+	}
+	s.deleted[n.ID] = deletedEntry{n: n, expires: now.Add(undoWindow)}
+}
+
+// takeDeleted removes and returns a recently deleted notification if it hasn't expired.
+// Taking it under the lock ensures concurrent undos can restore it only once.
+func (s *Server) takeDeleted(r *http.Request, id string) (*model.Notification, bool) {
+	s.deletedMu.Lock()
+	defer s.deletedMu.Unlock()
+
+	e, ok := s.deleted[id]
+	if !ok || time.Now().After(e.expires) || !canManage(r, &e.n) {
+		return nil, false
+	}
+	delete(s.deleted, id)
+	return &e.n, true
+}
+
+// undoTrigger builds the HX-Trigger header that shows the undo toast
+func undoTrigger(n model.Notification) string {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"notificationDeleted": map[string]interface{}{
+			"id":      n.ID,
+			"content": n.Content,
+			"seconds": int(undoWindow.Seconds()),
+		},
+	})
+	return string(payload)
+}
+
+// handleAPIUndoDelete restores a notification deleted within the last undoWindow
+func (s *Server) handleAPIUndoDelete(w http.ResponseWriter, r *http.Request, id string) {
+	n, ok := s.takeDeleted(r, id)
+	if !ok {
+		http.Error(w, "Nothing to undo: the deletion has expired", 404)
+		return
+	}
+
+	if err := s.store.AddNotification(n, actor(r)); err != nil {
+		http.Error(w, "Failed to restore: "+err.Error(), addErrorStatus(err))
+		return
+	}
+
+	s.worker.Refresh()
+	s.broadcastRefresh()
+
+	s.renderNotificationsList(w, r)
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// A fallback alert goes out once a notification has failed alertAfterFailures times
+// in a row, and at most once per alertInterval however many are failing
+const (
+	alertAfterFailures = 3
+	alertInterval      = 15 * time.Minute
+)
+
+// alertClient posts fallback alerts. It doesn't use the Pushover proxy: the point is
+// to get through when the way to Pushover doesn't.
+var alertClient = &http.Client{Timeout: 10 * time.Second}
+
+// fallbackAlert is the JSON posted to the fallback webhook. Text sums it up for chat
+// webhooks (Slack, Mattermost, Discord via /slack) that show only that field.
+type fallbackAlert struct {
+	Text           string    `json:"text"`
+	NotificationID string    `json:"notification_id"`
+	Content        string    `json:"content"`
+	Error          string    `json:"error"`
+	Failures       int       `json:"failures"`   // Failed attempts in a row
+	Suppressed     int       `json:"suppressed"` // Alerts held back by the rate limit since the last one
+	Time           time.Time `json:"time"`
+}
+
+// failureStreak counts n's failed send attempts since its last successful one
+func failureStreak(n *model.Notification) int {
+	count := 0
+	for i := len(n.History) - 1; i >= 0 && !n.History[i].OK; i-- {
+		count++
+	}
+	return count
+}
+
+// alertIfFailing posts a fallback alert about n when it has failed enough times in a
+// row and no alert went out within alertInterval. The post runs in the background so
+// a slow webhook doesn't hold up sending.
+func (w *Worker) alertIfFailing(n *model.Notification, webhookURL string, historyLen int, now time.Time) {
+	failures := failureStreak(n)
+	if webhookURL == "" || failures < min(alertAfterFailures, historyLen) {
+		return
+	}
+
+	w.statusMu.Lock()
+	if !w.lastAlert.IsZero() && now.Sub(w.lastAlert) < alertInterval {
+		w.suppressedAlerts++
+		w.statusMu.Unlock()
+		return
+	}
+	w.lastAlert = now
+	suppressed := w.suppressedAlerts
+	w.suppressedAlerts = 0
+	w.statusMu.Unlock()
+
+	alert := fallbackAlert{
+		Text:           fmt.Sprintf("Pushover sends are failing: %q failed %d times in a row (%s)", n.Content, failures, n.LastError),
+		NotificationID: n.ID,
+		Content:        n.Content,
+		Error:          n.LastError,
+		Failures:       failures,
+		Suppressed:     suppressed,
+		Time:           now,
+	}
+	go postAlert(webhookURL, alert, "Fallback alert sent", "id", alert.NotificationID, "failures", alert.Failures)
+}
+
+// postAlert posts alert to the fallback webhook, logging msg with attrs once it is accepted
+func postAlert(webhookURL string, alert any, msg string, attrs ...any) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		slog.Error("Failed to encode fallback alert", "error", err)
+		return
+	}
+	resp, err := alertClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to send fallback alert", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("Fallback webhook rejected the alert", "status", resp.Status)
+		return
+	}
+	slog.Info(msg, attrs...)
+}
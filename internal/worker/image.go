@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
+)
+
+// imageClient fetches images only from public addresses: image URLs come from any
+// writer, and the image is sent on to them, so a private address would let them read
+// internal services through the server. The check runs on the address actually dialed,
+// after DNS, so a name can't resolve past it. Proxies are not used, as they would dial
+// on the client's behalf.
+var imageClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				addr, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return err
+				}
+				if !IsPublicAddr(addr.Addr()) {
+					return fmt.Errorf("%w: %s", errPrivateAddr, addr.Addr())
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+}
+
+// errPrivateAddr is returned for an image on a loopback, private or link-local address
+var errPrivateAddr = errors.New("image address is not public")
+
+// cgnatPrefix is the shared address space carriers and some clouds use internally
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether images may be fetched from addr: it must not be
+// loopback, private, link-local (cloud metadata services included), multicast or
+// unspecified
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnatPrefix.Contains(addr)
+}
+
+// Images are fetched up to imageLead before their send, so a slow image server doesn't
+// hold the send up, and kept for up to imageMaxAge waiting for it
+const (
+	imageLead   = time.Minute
+	imageMaxAge = 5 * time.Minute
+)
+
+// imageCache holds images fetched in the background for upcoming sends, so fetching
+// them never holds up a scheduling pass
+type imageCache struct {
+	mu     sync.Mutex
+	images map[string]*fetchedImage
+}
+
+// fetchedImage is the outcome of fetching an image; fetched is zero while the fetch is
+// under way
+type fetchedImage struct {
+	fetched time.Time
+	data    []byte
+	mime    string
+	err     error
+}
+
+// prefetch starts fetching url in the background unless it has been already. done,
+// if set, is called once the fetch finishes.
+func (c *imageCache) prefetch(url string, done func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for u, img := range c.images {
+		if !img.fetched.IsZero() && now.Sub(img.fetched) > imageMaxAge {
+			delete(c.images, u)
+		}
+	}
+	if _, ok := c.images[url]; ok {
+		return
+	}
+	if c.images == nil {
+		c.images = make(map[string]*fetchedImage)
+	}
+	img := &fetchedImage{}
+	c.images[url] = img
+	go func() {
+		data, mime, err := fetchImage(url)
+		c.mu.Lock()
+		img.data, img.mime, img.err, img.fetched = data, mime, err, time.Now()
+		c.mu.Unlock()
+		if done != nil {
+			done()
+		}
+	}()
+}
+
+// take returns the image fetched from url and forgets it, so the next send fetches it
+// afresh. It returns nil until a fetch has finished.
+func (c *imageCache) take(url string) *fetchedImage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img := c.images[url]
+	if img == nil || img.fetched.IsZero() || time.Since(img.fetched) > imageMaxAge {
+		return nil
+	}
+	delete(c.images, url)
+	return img
+}
+
+// fetchImage downloads an image for use as a Pushover attachment
+func fetchImage(url string) ([]byte, string, error) {
+	resp, err := imageClient.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Read one byte past the limit to detect oversized images
+	data, err := io.ReadAll(io.LimitReader(resp.Body, pushover.MaxAttachmentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > pushover.MaxAttachmentSize {
+		return nil, "", fmt.Errorf("image exceeds %d bytes", pushover.MaxAttachmentSize)
+	}
+
+	mime := resp.Header.Get("Content-Type")
+	if i := strings.Index(mime, ";"); i >= 0 {
+		mime = mime[:i]
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		mime = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return nil, "", fmt.Errorf("not an image: %s", mime)
+	}
+
+	return data, mime, nil
+}
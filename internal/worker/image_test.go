@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.100.100.200", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := IsPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestImageFromPrivateAddressRefused(t *testing.T) {
+	var fetches atomic.Int32
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	t.Cleanup(images.Close)
+
+	w, store, api := newTestWorker(t)
+	addNotification(t, store, &model.Notification{
+		ID:             "camera",
+		Content:        "Front door",
+		ScheduledTime:  time.Now().Add(-time.Second),
+		TotalSends:     1,
+		RepeatInterval: "1h",
+		ImageURL:       images.URL + "/snapshot.png",
+	})
+
+	// The first pass only starts fetching the image, and is woken once that is done
+	w.checkAndProcess(context.Background())
+	if got := len(api.Requests()); got != 0 {
+		t.Fatalf("sent %d messages before the image was fetched", got)
+	}
+	select {
+	case <-w.updateChan:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker not woken after the image fetch")
+	}
+
+	w.checkAndProcess(context.Background())
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(requests))
+	}
+	if requests[0].Has("attachment_base64") {
+		t.Error("image from a loopback address attached")
+	}
+	if got := fetches.Load(); got != 0 {
+		t.Errorf("image server received %d requests, want none", got)
+	}
+	if _, _, err := fetchImage(images.URL + "/snapshot.png"); !errors.Is(err, errPrivateAddr) {
+		t.Errorf("fetchImage error = %v, want %v", err, errPrivateAddr)
+	}
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// logScheduleLimit is how many notifications LogSchedule lists one by one
+const logScheduleLimit = 100
+
+// LogSchedule logs what the worker is about to do: each pending notification with
+// its next send time, soonest first, and anything holding all sends back. It is run
+// once at startup so the plan can be checked from the logs, e.g. after a report that
+// nothing fired overnight.
+func (w *Worker) LogSchedule() {
+	settings := w.store.GetSettings()
+	pending := w.store.GetPending()
+	now := time.Now()
+
+	slog.Info("Send schedule", "pending", len(pending))
+	if len(pending) == 0 {
+		return
+	}
+	if settings.PushoverToken == "" || settings.PushoverUser == "" {
+		slog.Warn("Nothing will be sent until the Pushover credentials are set")
+	}
+	if now.Before(settings.MutedUntil) {
+		slog.Warn("Nothing will be sent while muted", "until", settings.MutedUntil.Format("2006-01-02 15:04:05"))
+	}
+	if w.paused.Load() {
+		slog.Warn("Nothing will be sent in maintenance mode")
+	}
+	if w.standby.Load() {
+		slog.Warn("Nothing will be sent while another instance is the sender")
+	}
+
+	type planned struct {
+		n    *model.Notification
+		next time.Time
+	}
+	plan := make([]planned, len(pending))
+	for i, n := range pending {
+		plan[i] = planned{n, w.NextSendTime(n, settings)}
+	}
+	slices.SortFunc(plan, func(a, b planned) int { return a.next.Compare(b.next) })
+
+	for i, p := range plan {
+		if i == logScheduleLimit {
+			slog.Info("Send schedule truncated", "not_listed", len(plan)-i)
+			break
+		}
+		n := p.n
+		sends := fmt.Sprintf("%d of %d", n.SendsCount+1, seriesLength(n, settings.SendMode))
+		if !n.RepeatUntil.IsZero() && !n.StopOnFirstDelivery {
+			sends = fmt.Sprintf("%d, until %s", n.SendsCount+1, n.RepeatUntil.Format("2006-01-02 15:04"))
+		}
+		attrs := []any{"id", n.ID, "content", n.Content, "next", p.next.Format("2006-01-02 15:04:05"), "send", sends}
+		if p.next.Before(now) {
+			attrs = append(attrs, "overdue", now.Sub(p.next).Round(time.Second))
+		}
+		if n.Paused {
+			attrs = append(attrs, "paused", true)
+		}
+		if n.LastError != "" {
+			attrs = append(attrs, "last_error", n.LastError)
+		}
+		slog.Info("Scheduled send", attrs...)
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket capping the rate of outbound sends: up to burst
+// sends go out back to back, after which they are spaced 1/rate seconds apart. A
+// zero rate means no limit.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64   // Tokens added per second
+	burst  float64   // Bucket size
+	tokens float64   // Tokens left; negative when sends are queued behind the limit
+	last   time.Time // When tokens was last topped up
+}
+
+// set changes the rate and burst, starting with a full bucket; setting them to what
+// they already are (a config reload) leaves the bucket as it is
+func (l *rateLimiter) set(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rate, b := max(rate, 0), float64(max(burst, 1))
+	if rate == l.rate && b == l.burst {
+		return
+	}
+	l.rate, l.burst = rate, b
+	l.tokens = l.burst
+	l.last = time.Time{}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait blocks until a send may go out under the limit, or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	var l rateLimiter
+	l.set(2, 3) // Three back to back, then one every 500ms
+	start := time.Date(2026, time.October, 16, 9, 0, 0, 0, time.UTC)
+
+	// Six sends due at once: the burst goes now, the rest are queued behind it
+	want := []time.Duration{0, 0, 0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}
+	for i, w := range want {
+		if got := l.reserve(start); got != w {
+			t.Errorf("send %d waits %s, want %s", i+1, got, w)
+		}
+	}
+
+	// Once the queue has drained, the bucket refills at the rate
+	later := start.Add(1500*time.Millisecond + time.Second)
+	for i, w := range []time.Duration{0, 0, 500 * time.Millisecond} {
+		if got := l.reserve(later); got != w {
+			t.Errorf("after refilling, send %d waits %s, want %s", i+1, got, w)
+		}
+	}
+}
+
+func TestRateLimiterSpacing(t *testing.T) {
+	const interval = 40 * time.Millisecond
+	var l rateLimiter
+	l.set(float64(time.Second/interval), 1)
+
+	var sent []time.Time
+	for range 4 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+		sent = append(sent, time.Now())
+	}
+	for i := 1; i < len(sent); i++ {
+		// Allow for the clock readings around each wait
+		if gap := sent[i].Sub(sent[i-1]); gap < interval-time.Millisecond {
+			t.Errorf("sends %d and %d %s apart, want at least %s", i, i+1, gap, interval)
+		}
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	var l rateLimiter
+	l.set(0, 0)
+	for range 100 {
+		if d := l.reserve(time.Now()); d != 0 {
+			t.Fatalf("unlimited send waits %s", d)
+		}
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	var l rateLimiter
+	l.set(0.01, 1) // One send, then one every 100s
+	l.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait = %v, want it cut short by the context", err)
+	}
+}
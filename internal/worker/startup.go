@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/pushover"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// startupResendWindow is how long after a startup send further restarts skip it, so a
+// crash loop or a run of quick restarts doesn't flood the device
+const startupResendWindow = time.Hour
+
+// SetStartupDelay makes Start wait d before sending anything, startup sends included.
+// During a rolling deploy the old instance may still be running for a while; holding
+// the new one back until it has stopped keeps both from sending the same reminder.
+func (w *Worker) SetStartupDelay(d time.Duration) {
+	w.startupDelay = max(d, 0)
+}
+
+// waitStartupDelay waits out the startup delay, reporting false if ctx is cancelled
+// first. Status shows the end of the delay as the next run meanwhile.
+func (w *Worker) waitStartupDelay(ctx context.Context) bool {
+	if w.startupDelay <= 0 {
+		return true
+	}
+	until := time.Now().Add(w.startupDelay)
+	slog.Info("Waiting before the first send", "delay", w.startupDelay, "until", until.Format("15:04:05"))
+	w.statusMu.Lock()
+	w.nextRun = until
+	w.statusMu.Unlock()
+
+	timer := time.NewTimer(w.startupDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sendOnStartup sends each pending notification marked SendOnStartup once, on top of
+// its series: it doesn't count as one of the series' sends or move the next one. The
+// send time is saved before sending, so even a restart in the middle of it doesn't
+// repeat a send within startupResendWindow. Nothing is sent while paused, on standby
+// or muted.
+func (w *Worker) sendOnStartup() {
+	settings := w.store.GetSettings()
+	now := time.Now()
+	if w.holdingBack() || now.Before(settings.MutedUntil) || settings.PushoverToken == "" || settings.PushoverUser == "" {
+		return
+	}
+
+	startupDue := func(n *model.Notification) bool {
+		return n.Status != model.StatusDone && n.SendOnStartup && !n.Paused && now.Sub(n.StartupSentAt) >= startupResendWindow
+	}
+	if !slices.ContainsFunc(w.store.GetPending(), startupDue) {
+		return
+	}
+	var due []*model.Notification
+	err := w.store.WithTransaction(func(tx *storage.Tx) error {
+		due = nil
+		for _, n := range tx.Notifications() {
+			if !startupDue(n) {
+				continue
+			}
+			updated := *n
+			updated.StartupSentAt = now
+			if err := tx.ReplaceNotification(&updated); err != nil {
+				return err
+			}
+			due = append(due, &updated)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to save store; skipping startup sends", "error", err)
+		return
+	}
+
+	for _, n := range due {
+		slog.Info("Sending notification at startup", "id", n.ID, "content", n.Content)
+		if err := w.SendDirect(w.BuildMessage(n, settings)); err != nil {
+			slog.Error("Failed to send notification at startup", "id", n.ID, "error", err)
+			w.store.AppendAudit(model.AuditEvent{Action: model.AuditSendFailed, NotificationID: n.ID, Content: n.Content, Actor: "worker", Detail: "startup: " + pushover.ErrorMessage(err)})
+			continue
+		}
+		w.store.AppendAudit(model.AuditEvent{Action: model.AuditSend, NotificationID: n.ID, Content: n.Content, Actor: "worker", Detail: "startup"})
+	}
+}
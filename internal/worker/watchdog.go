@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+)
+
+// watchdogInterval is how often the watchdog looks for overdue notifications
+const watchdogInterval = time.Minute
+
+// overdueAlert is the JSON posted to the fallback webhook when reminders pile up
+// unsent. Text sums it up for chat webhooks, as in fallbackAlert.
+type overdueAlert struct {
+	Text          string    `json:"text"`
+	Overdue       int       `json:"overdue"` // Notifications overdue by more than the threshold
+	OldestID      string    `json:"oldest_id"`
+	OldestContent string    `json:"oldest_content"`
+	OldestDue     time.Time `json:"oldest_due"`
+	Time          time.Time `json:"time"`
+}
+
+// SetOverdueAlertAfter sets how long past due a notification may be before the
+// watchdog alerts; 0 turns the watchdog off
+func (w *Worker) SetOverdueAlertAfter(d time.Duration) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	w.overdueAfter = max(d, 0)
+}
+
+// RunWatchdog checks every watchdogInterval, until ctx is cancelled, for pending
+// notifications whose next send is overdue by more than the configured threshold.
+// That happens when sending is held back, e.g. by missing or rejected credentials or
+// a maintenance mode left on, or when the worker is stuck. It posts one summary alert
+// to the fallback webhook when notifications start piling up, and another only once
+// they have all caught up and a new pile-up starts. It runs apart from the worker loop so that a
+// stuck loop is noticed too. Paused notifications and a global mute are deliberate
+// and don't count.
+func (w *Worker) RunWatchdog(ctx context.Context) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	alerted := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		w.configMu.RLock()
+		threshold := w.overdueAfter
+		w.configMu.RUnlock()
+		if threshold <= 0 {
+			alerted = false
+			continue
+		}
+
+		settings := w.store.GetSettings()
+		now := time.Now()
+		overdue, oldest, oldestDue := w.findOverdue(settings, now, threshold)
+		if overdue == 0 {
+			alerted = false // Caught up: a new pile-up alerts again
+			continue
+		}
+		if alerted {
+			continue
+		}
+		alerted = true
+
+		slog.Warn("Notifications are overdue", "count", overdue, "threshold", threshold, "oldest", oldest.ID, "due", oldestDue.Format("2006-01-02 15:04:05"))
+		if settings.FallbackWebhookURL == "" {
+			continue
+		}
+		alert := overdueAlert{
+			Text: fmt.Sprintf("Reminders are piling up: %d overdue by more than %s, the oldest %q due since %s",
+				overdue, threshold, oldest.Content, oldestDue.Format("2006-01-02 15:04")),
+			Overdue:       overdue,
+			OldestID:      oldest.ID,
+			OldestContent: oldest.Content,
+			OldestDue:     oldestDue,
+			Time:          now,
+		}
+		go postAlert(settings.FallbackWebhookURL, alert, "Overdue alert sent", "count", overdue)
+	}
+}
+
+// findOverdue counts the pending notifications whose next send was due more than
+// threshold before now, and returns the one overdue longest with its due time. It runs
+// alongside the worker's passes, so it reads the store's snapshot of the pending
+// notifications: sends replace them in the store rather than change them.
+func (w *Worker) findOverdue(settings model.Settings, now time.Time, threshold time.Duration) (count int, oldest *model.Notification, oldestDue time.Time) {
+	if now.Before(settings.MutedUntil) {
+		return 0, nil, time.Time{}
+	}
+	for _, n := range w.store.GetPending() {
+		if n.Paused || !w.inSeries(n, settings) {
+			continue
+		}
+		due := w.NextSendTime(n, settings)
+		if now.Sub(due) <= threshold {
+			continue
+		}
+		count++
+		if oldest == nil || due.Before(oldestDue) {
+			oldest, oldestDue = n, due
+		}
+	}
+	return count, oldest, oldestDue
+}
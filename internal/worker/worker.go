@@ -2,27 +2,185 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
 	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/noahxzhu/pushover-notify/internal/model"
 	"github.com/noahxzhu/pushover-notify/internal/pushover"
 	"github.com/noahxzhu/pushover-notify/internal/storage"
 )
 
 type Worker struct {
-	store      *storage.Store
+	store      storage.Backend
 	client     *pushover.Client
 	updateChan chan struct{}
-	onUpdate   func() // Callback when notifications are updated
+	onUpdate   func()        // Callback when notifications are updated
+	onTick     func()        // Callback after each scheduling pass
+	precision  time.Duration // Send times are truncated to this: a minute, or a second when keeping seconds
+	paused     atomic.Bool   // Set in maintenance mode: nothing is sent or deleted
+	standby    atomic.Bool   // Set while another instance is the sender: nothing is sent or deleted
+
+	startupDelay time.Duration // Wait before the first pass; see SetStartupDelay
+
+	configMu     sync.RWMutex  // Guards the settings a config reload can change
+	ackBaseURL   string        // Public base URL for acknowledge links; empty disables them
+	apiBaseURL   string        // Pushover API root; empty uses the official API
+	httpClient   *http.Client  // Client for Pushover requests; nil uses http.DefaultClient
+	historyLen   int           // Send attempts kept per notification
+	trimNeeded   bool          // historyLen was lowered: existing histories may be longer
+	replies      bool          // Quote each notification's reply token in its messages
+	overdueAfter time.Duration // How long past due before the watchdog alerts; 0 disables it
+
+	limiter rateLimiter // Caps the rate of outbound sends
+	images  imageCache  // Images fetched ahead of their sends
+
+	statusMu         sync.Mutex
+	nextRun          time.Time
+	lastTick         time.Time
+	sendTimes        []time.Time // Successful sends within the last hour
+	credentialsError string      // Why Pushover rejected the credentials; cleared by a successful send
+	rejectedToken    string      // The credentials credentialsError is about
+	rejectedUser     string
+	lastAlert        time.Time // When the last fallback alert went out
+	suppressedAlerts int       // Alerts held back since then
+}
+
+// Status is a snapshot of the worker's scheduling state
+type Status struct {
+	Idle          bool
+	Paused        bool
+	Standby       bool // Another instance sends from the same data file
+	NextRun       time.Time
+	LastTick      time.Time
+	SendsLastHour int
+	// CredentialsError is set while Pushover rejects the saved token or user key
+	CredentialsError string
 }
 
-func NewWorker(store *storage.Store) *Worker {
+func NewWorker(store storage.Backend) *Worker {
 	return &Worker{
 		store:      store,
 		client:     &pushover.Client{},
 		updateChan: make(chan struct{}, 1),
+		precision:  time.Minute,
+		historyLen: DefaultHistoryLimit,
+	}
+}
+
+// recheckInterval is the longest the worker sleeps without checking the store for
+// changes that didn't call Refresh, such as the data file being edited on disk
+const recheckInterval = time.Minute
+
+// credentialsWarnInterval is how often the worker logs that notifications are
+// waiting for Pushover credentials, and retries credentials Pushover rejected
+const credentialsWarnInterval = 15 * time.Minute
+
+// retryDelay is how long a send waits after a temporary failure, and
+// rateLimitedRetryDelay after Pushover reports the message quota used up
+const (
+	retryDelay            = time.Minute
+	rateLimitedRetryDelay = 15 * time.Minute
+)
+
+// ErrNoCredentials is returned by SendDirect before the Pushover token and user key are set
+var ErrNoCredentials = errors.New("Pushover token and user key are not set")
+
+// DefaultHistoryLimit is how many send attempts each notification keeps by default
+const DefaultHistoryLimit = 20
+
+// SetHistoryLimit sets how many send attempts each notification keeps; 0 uses the default.
+// A lower limit applies to every notification, Done ones included, on the next pass.
+func (w *Worker) SetHistoryLimit(n int) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	old := w.historyLen
+	w.historyLen = DefaultHistoryLimit
+	if n > 0 {
+		w.historyLen = n
+	}
+	if w.historyLen < old {
+		w.trimNeeded = true
+		w.Refresh()
+	}
+}
+
+// trimHistories cuts every notification's history down to the limit after it was
+// lowered. Sends only trim the history they add to, which would leave Done
+// notifications, and pending ones until their next send, as long as before.
+func (w *Worker) trimHistories() {
+	w.configMu.Lock()
+	limit, needed := w.historyLen, w.trimNeeded
+	w.trimNeeded = false
+	w.configMu.Unlock()
+	if !needed {
+		return
+	}
+
+	tooLong := func(n *model.Notification) bool { return len(n.History) > limit }
+	if !slices.ContainsFunc(w.store.GetAllNotifications(), tooLong) {
+		return
+	}
+	trimmed := 0
+	err := w.store.WithTransaction(func(tx *storage.Tx) error {
+		trimmed = 0
+		for _, n := range tx.Notifications() {
+			if !tooLong(n) {
+				continue
+			}
+			updated := *n
+			updated.TrimHistory(limit)
+			if err := tx.ReplaceNotification(&updated); err != nil {
+				return err
+			}
+			trimmed++
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to save store", "error", err)
+		return
 	}
+	slog.Info("Send histories trimmed to the new limit", "limit", limit, "notifications", trimmed)
+}
+
+// replyRefPrefix introduces the reply token quoted at the end of a message
+const replyRefPrefix = "Ref: "
+
+// SetReplies quotes each notification's reply token at the end of its messages, for a
+// bridge that posts replies back to /reply. Tokens are assigned on the next send.
+func (w *Worker) SetReplies(enabled bool) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	w.replies = enabled
+}
+
+// SetPaused stops (or resumes) sending and auto-deletion. Due notifications are sent
+// once resumed.
+func (w *Worker) SetPaused(paused bool) {
+	w.paused.Store(paused)
+	w.Refresh()
+}
+
+// SetStandby stops (or resumes) sending and auto-deletion while another instance
+// holds the sender lock on the same data file
+func (w *Worker) SetStandby(standby bool) {
+	w.standby.Store(standby)
+	w.Refresh()
+}
+
+// holdingBack reports whether the worker must not send or delete anything now
+func (w *Worker) holdingBack() bool {
+	return w.paused.Load() || w.standby.Load()
 }
 
 // SetOnUpdate sets a callback function that will be called when notifications are updated
@@ -30,6 +188,84 @@ func (w *Worker) SetOnUpdate(fn func()) {
 	w.onUpdate = fn
 }
 
+// SetKeepSeconds schedules to the second instead of truncating send times to the
+// minute. It must match the web server's setting, so times keep their seconds from
+// submission to sending.
+func (w *Worker) SetKeepSeconds(enabled bool) {
+	w.precision = time.Minute
+	if enabled {
+		w.precision = time.Second
+	}
+}
+
+// SetAPIBaseURL routes messages through another Pushover-compatible endpoint, such as
+// an internal relay. Empty uses the official API.
+func (w *Worker) SetAPIBaseURL(baseURL string) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	w.apiBaseURL = baseURL
+}
+
+// SetProxy sends Pushover requests through an HTTP(S) or SOCKS5 proxy URL, which may
+// carry credentials. Empty falls back to the HTTP_PROXY/HTTPS_PROXY environment.
+func (w *Worker) SetProxy(proxy string) error {
+	httpClient, err := pushover.NewHTTPClient(proxy)
+	if err != nil {
+		return err
+	}
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	w.httpClient = httpClient
+	return nil
+}
+
+// SetRateLimit caps outbound sends at rate per second, letting up to burst go out
+// back to back. When more are due at once they are spaced out rather than dropped.
+// A zero rate removes the limit.
+func (w *Worker) SetRateLimit(rate float64, burst int) {
+	w.limiter.set(rate, burst)
+}
+
+// SetOnTick sets a callback function that will be called after each scheduling pass
+func (w *Worker) SetOnTick(fn func()) {
+	w.onTick = fn
+}
+
+// Status returns the current scheduling state
+func (w *Worker) Status() Status {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+
+	w.pruneSendTimes(time.Now())
+	return Status{
+		Idle:             w.nextRun.IsZero(),
+		Paused:           w.paused.Load(),
+		Standby:          w.standby.Load(),
+		NextRun:          w.nextRun,
+		LastTick:         w.lastTick,
+		SendsLastHour:    len(w.sendTimes),
+		CredentialsError: w.credentialsError,
+	}
+}
+
+// pruneSendTimes drops sends older than an hour. Caller must hold statusMu.
+func (w *Worker) pruneSendTimes(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+	i := 0
+	for i < len(w.sendTimes) && w.sendTimes[i].Before(cutoff) {
+		i++
+	}
+	w.sendTimes = w.sendTimes[i:]
+}
+
+// SetAckBaseURL sets the public base URL used to build acknowledge links and the
+// emergency acknowledgement callback
+func (w *Worker) SetAckBaseURL(baseURL string) {
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	w.ackBaseURL = strings.TrimRight(baseURL, "/")
+}
+
 // Refresh signals the worker to re-evaluate the schedule immediately
 func (w *Worker) Refresh() {
 	select {
@@ -45,39 +281,59 @@ func (w *Worker) Start(ctx context.Context) {
 	timer := time.NewTimer(time.Hour) // Initial long duration
 	timer.Stop()                      // Stop immediately, we'll reset it
 
+	if !w.waitStartupDelay(ctx) {
+		slog.Info("Worker stopped")
+		return
+	}
+	w.sendOnStartup()
+
+	var nextRun time.Time
+	var seen uint64    // Store version as of the last pass
+	unchanged := false // Set when a recheck found the store as it was
 	for {
-		// 1. Process due items and calculate next run time
-		nextRun := w.checkAndProcess()
-
-		// 2. Set timer
-		now := time.Now()
-		var duration time.Duration
-
-		if nextRun.IsZero() {
-			// No pending items, wait indefinitely (via updateChan)
-			// effectively stop timer
-			if !timer.Stop() {
-				select {
-				case <-timer.C:
-				default:
+		// 1. Process due items and calculate next run time; while paused or on standby,
+		// idle until resumed
+		if !unchanged {
+			seen = w.store.Version()
+			nextRun = time.Time{}
+			if !w.holdingBack() {
+				w.trimHistories()
+				nextRun = w.checkAndProcess(ctx)
+				if next := w.deleteExpired(); !next.IsZero() && (nextRun.IsZero() || next.Before(nextRun)) {
+					nextRun = next
 				}
 			}
-			slog.Info("No pending notifications. Worker idle.")
-		} else {
-			duration = nextRun.Sub(now)
-			if duration < 0 {
-				duration = 0 // Run immediately
+
+			w.statusMu.Lock()
+			w.nextRun = nextRun
+			w.lastTick = time.Now()
+			w.statusMu.Unlock()
+			if w.onTick != nil {
+				w.onTick()
 			}
 
-			// Reset timer
-			if !timer.Stop() {
-				select {
-				case <-timer.C:
-				default:
-				}
+			if nextRun.IsZero() {
+				slog.Info("No pending notifications. Worker idle.")
+			} else {
+				slog.Info("Next check scheduled", "in", max(time.Until(nextRun), 0), "at", nextRun.Format("15:04:05"))
 			}
-			timer.Reset(duration)
-			slog.Info("Next check scheduled", "in", duration, "at", nextRun.Format("15:04:05"))
+		}
+		unchanged = false
+
+		// 2. Set timer. Changes made through the app wake us via updateChan, but the data
+		// file can also change on disk (a restore, or credentials added by hand), so don't
+		// sleep longer than recheckInterval.
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		recheck := nextRun.IsZero() || time.Until(nextRun) > recheckInterval
+		if recheck {
+			timer.Reset(recheckInterval)
+		} else {
+			timer.Reset(max(time.Until(nextRun), 0)) // Overdue: run immediately
 		}
 
 		// 3. Wait for event
@@ -89,98 +345,482 @@ func (w *Worker) Start(ctx context.Context) {
 			slog.Info("Worker received update signal. Refreshing...")
 			// Continue loop -> re-check
 		case <-timer.C:
-			// Timer fired -> Continue loop -> re-check
+			// Timer fired -> Continue loop -> re-check. On an early wake-up, skip the pass
+			// unless the store changed; reading it reloads the data file if that changed.
+			if recheck {
+				w.store.GetSettings()
+				unchanged = w.store.Version() == seen
+			}
 		}
 	}
 }
 
-// checkAndProcess sends due notifications and returns the time of the NEXT scheduled event
-func (w *Worker) checkAndProcess() time.Time {
+// checkAndProcess sends due notifications and returns the time of the NEXT scheduled
+// event. Sends held back by the rate limit are given up when ctx is done.
+func (w *Worker) checkAndProcess(ctx context.Context) time.Time {
 	settings := w.store.GetSettings()
 
-	// If credentials missing, we can't send
+	// If credentials missing, we can't send. Saving them refreshes the worker; until
+	// then, keep warning while notifications are waiting.
 	if settings.PushoverToken == "" || settings.PushoverUser == "" {
-		return time.Time{} // Return zero to idle
+		waiting := len(w.store.GetPending())
+		if waiting == 0 {
+			return time.Time{} // Return zero to idle
+		}
+		slog.Warn("Pushover credentials not set; notifications are waiting", "pending", waiting)
+		return time.Now().Add(credentialsWarnInterval)
+	}
+
+	// Credentials Pushover rejected no longer apply once they are changed
+	w.statusMu.Lock()
+	if settings.PushoverToken != w.rejectedToken || settings.PushoverUser != w.rejectedUser {
+		w.credentialsError = ""
 	}
+	w.statusMu.Unlock()
 
+	w.configMu.RLock()
 	w.client.Token = settings.PushoverToken
 	w.client.User = settings.PushoverUser
+	w.client.BaseURL = w.apiBaseURL
+	w.client.HTTPClient = w.httpClient
+	historyLen := w.historyLen
+	replies := w.replies
+	w.configMu.RUnlock()
+
+	// While muted, defer everything without touching counts; wake up when the mute expires
+	if time.Now().Before(settings.MutedUntil) {
+		slog.Info("Notifications muted", "until", settings.MutedUntil.Format("2006-01-02 15:04:05"))
+		return settings.MutedUntil
+	}
 
 	pending := w.store.GetPending()
 	now := time.Now()
 	saveNeeded := false
+	credentialsRejected := false
 
 	var earliestNext time.Time
 
 	for _, n := range pending {
-		// Use per-notification settings
-		repeatInterval, err := time.ParseDuration(n.RepeatInterval)
-		if err != nil {
-			repeatInterval = 30 * time.Minute
+		if n.Paused {
+			continue
 		}
 
-		repeatTimes := n.RepeatTimes
-		if repeatTimes == 0 {
-			repeatTimes = 3
-		}
+		// Use per-notification settings
+		totalSends := seriesLength(n, settings.SendMode)
+		untilMode := !n.RepeatUntil.IsZero() && !n.StopOnFirstDelivery
 
 		// Calculate when this notification SHOULD be sent next
-		var nextSendTime time.Time
-
-		if n.SendsCount == 0 {
-			nextSendTime = n.ScheduledTime.Truncate(time.Minute)
-		} else {
-			// Calculate next time based on original scheduled time + intervals
-			// This ensures all repeats are at XX:XX:00
-			nextSendTime = n.ScheduledTime.Truncate(time.Minute).Add(repeatInterval * time.Duration(n.SendsCount))
-		}
+		nextSendTime := w.NextSendTime(n, settings)
 
 		// Check if it's due now (or past due)
 		if !now.Before(nextSendTime) {
 			// IT IS DUE
-			if n.SendsCount < repeatTimes {
+			if w.inSeries(n, settings) {
+				var image *fetchedImage
+				if n.ImageURL != "" {
+					if image = w.images.take(n.ImageURL); image == nil {
+						// Fetched in the background, not to hold up the other sends; the
+						// worker is woken to send once it has been
+						w.images.prefetch(n.ImageURL, w.Refresh)
+						continue
+					}
+				}
+				if err := w.limiter.wait(ctx); err != nil {
+					break // Shutting down: the rest go out after a restart
+				}
+				now = time.Now() // The rate limit may have held the send back
 				delay := now.Sub(nextSendTime)
-				slog.Info("Sending notification", "content", n.Content, "attempt", n.SendsCount+1, "max", repeatTimes, "scheduled", nextSendTime.Format("15:04:05"), "delay", delay)
-				err := w.client.SendMessage("Reminder", n.Content)
+				slog.Info("Sending notification", "content", n.Content, "attempt", n.SendsCount+1, "max", totalSends, "scheduled", nextSendTime.Format("15:04:05"), "delay", delay)
+				sending := *n
+				if replies && sending.ReplyToken == "" {
+					sending.ReplyToken = uuid.New().String()
+				}
+				msg := w.BuildMessage(&sending, settings)
+				if image != nil {
+					// Fall back to a text-only message if the image couldn't be fetched
+					if image.err != nil {
+						slog.Warn("Failed to fetch image, sending text only", "id", n.ID, "url", n.ImageURL, "error", image.err)
+					} else {
+						msg.Attachment = image.data
+						msg.AttachmentType = image.mime
+					}
+				}
+				receipt, err := w.client.SendWithReceipt(msg)
+
+				// An edit may have replaced the notification while it was being sent, so
+				// the send is recorded on the notification as it now stands
+				saved, ok := w.commit(n.ID, func(n *model.Notification) {
+					if n.ReplyToken == "" {
+						n.ReplyToken = sending.ReplyToken
+					}
+					recordSend(n, receipt, err, now, historyLen)
+				})
+				if ok {
+					n = saved
+					saveNeeded = true
+				} else {
+					recordSend(&sending, receipt, err, now, historyLen)
+					n = &sending
+				}
 				if err != nil {
 					slog.Error("Failed to send pushover message", "error", err)
-					// Update LastPushTime even on failure to avoid spamming
-					n.LastPushTime = now
-					saveNeeded = true
+					reason := pushover.ErrorMessage(err)
+					w.store.AppendAudit(model.AuditEvent{Action: model.AuditSendFailed, NotificationID: n.ID, Content: n.Content, Actor: "worker", Detail: reason})
+					w.alertIfFailing(n, settings.FallbackWebhookURL, historyLen, now)
+					if errors.Is(err, pushover.ErrInvalidCredentials) {
+						// Every send would fail the same way: stop until the settings change
+						w.statusMu.Lock()
+						w.credentialsError = reason
+						w.rejectedToken, w.rejectedUser = settings.PushoverToken, settings.PushoverUser
+						w.statusMu.Unlock()
+						credentialsRejected = true
+					}
 				} else {
-					n.SendsCount++
-					n.LastPushTime = now
-					saveNeeded = true
+					w.statusMu.Lock()
+					w.sendTimes = append(w.sendTimes, now)
+					w.pruneSendTimes(now)
+					w.credentialsError = ""
+					w.statusMu.Unlock()
+					detail := fmt.Sprintf("attempt %d of %d", n.SendsCount, totalSends)
+					if untilMode {
+						detail = fmt.Sprintf("attempt %d, until %s", n.SendsCount, n.RepeatUntil.Format("2006-01-02 15:04"))
+					}
+					w.store.AppendAudit(model.AuditEvent{Action: model.AuditSend, NotificationID: n.ID, Content: n.Content, Actor: "worker", Detail: detail})
+				}
+				if !ok {
+					continue // Deleted meanwhile, or not saved: the next pass sees it as it is
 				}
 			}
+			if credentialsRejected {
+				break
+			}
 
-			if n.SendsCount >= repeatTimes {
-				n.Status = model.StatusDone
-				saveNeeded = true
-				slog.Info("Notification marked as Done", "id", n.ID)
+			if !w.inSeries(n, settings) {
+				// Checked again on the stored notification, which an edit may have extended
+				done, ok := w.commit(n.ID, func(n *model.Notification) {
+					if !w.inSeries(n, settings) {
+						n.Status = model.StatusDone
+					}
+				})
+				if ok {
+					saveNeeded = true
+					if done.Status == model.StatusDone {
+						slog.Info("Notification marked as Done", "id", n.ID)
+					}
+				}
 			} else {
 				// Calculate NEXT time for this item after processing
-				// Use scheduled time + intervals to keep at XX:XX:00
-				nextForThis := n.ScheduledTime.Truncate(time.Minute).Add(repeatInterval * time.Duration(n.SendsCount))
+				nextForThis := w.NextSendTime(n, settings)
 				if earliestNext.IsZero() || nextForThis.Before(earliestNext) {
 					earliestNext = nextForThis
 				}
 			}
 		} else {
-			// Not due yet, track its next time
-			if earliestNext.IsZero() || nextSendTime.Before(earliestNext) {
-				earliestNext = nextSendTime
+			// Not due yet, track its next time. An image is fetched ahead of the send,
+			// so wake up for that first.
+			wake := nextSendTime
+			if n.ImageURL != "" {
+				if lead := nextSendTime.Add(-imageLead); now.Before(lead) {
+					wake = lead
+				} else {
+					w.images.prefetch(n.ImageURL, nil)
+				}
+			}
+			if earliestNext.IsZero() || wake.Before(earliestNext) {
+				earliestNext = wake
 			}
 		}
 	}
 
-	if saveNeeded {
-		if err := w.store.Save(); err != nil {
-			slog.Error("Failed to save store", "error", err)
-		} else if w.onUpdate != nil {
-			w.onUpdate()
+	if saveNeeded && w.onUpdate != nil {
+		w.onUpdate()
+	}
+
+	if credentialsRejected {
+		// Saving new credentials refreshes the worker; until then, try again now and then
+		return time.Now().Add(credentialsWarnInterval)
+	}
+	return earliestNext
+}
+
+// recordSend updates n with the outcome of a send attempt made at now
+func recordSend(n *model.Notification, receipt string, err error, now time.Time, historyLen int) {
+	n.LastPushTime = now
+	if err != nil {
+		reason := pushover.ErrorMessage(err)
+		n.LastError = reason
+		n.RecordAttempt(model.SendAttempt{Time: now, Error: reason}, historyLen)
+		switch {
+		case errors.Is(err, pushover.ErrInvalidCredentials):
+			// Not the message's fault: it goes out once the credentials are fixed
+		case errors.Is(err, pushover.ErrRateLimited):
+			n.RetryAt = now.Add(rateLimitedRetryDelay)
+		case errors.Is(err, pushover.ErrTransient):
+			n.RetryAt = now.Add(retryDelay)
+		default:
+			// Rejected for good, e.g. a message Pushover won't accept: retrying
+			// can't help, so skip this send
+			n.SendsCount++
+		}
+		return
+	}
+	n.SendsCount++
+	n.LastError = ""
+	n.RetryAt = time.Time{}
+	n.RecordAttempt(model.SendAttempt{Time: now, OK: true}, historyLen)
+	if receipt != "" {
+		n.AddReceipt(receipt)
+	}
+}
+
+// commit applies change to a copy of the notification with id as it stands in the
+// store, saves the copy in its place and returns it. It reports false if the
+// notification has been deleted or the change couldn't be saved.
+func (w *Worker) commit(id string, change func(n *model.Notification)) (*model.Notification, bool) {
+	var updated *model.Notification
+	err := w.store.WithTransaction(func(tx *storage.Tx) error {
+		current, err := tx.GetNotification(id)
+		if err != nil {
+			return err
+		}
+		copied := *current
+		change(&copied)
+		updated = &copied
+		return tx.ReplaceNotification(updated)
+	})
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			slog.Error("Failed to save store", "id", id, "error", err)
+		}
+		return nil, false
+	}
+	return updated, true
+}
+
+// seriesLength is how many sends n's series has when it repeats a set number of times
+func seriesLength(n *model.Notification, sendMode string) int {
+	if n.StopOnFirstDelivery {
+		return 1 // One-shot: skip the repeat loop
+	}
+	return n.SeriesLength(sendMode)
+}
+
+// inSeries reports whether n's next send is part of its series. In until mode the
+// first send always is, later ones while they fall due by RepeatUntil.
+func (w *Worker) inSeries(n *model.Notification, settings model.Settings) bool {
+	if !n.RepeatUntil.IsZero() && !n.StopOnFirstDelivery {
+		return n.SendsCount == 0 || !w.dueTime(n, settings).After(n.RepeatUntil)
+	}
+	return n.SendsCount < seriesLength(n, settings.SendMode)
+}
+
+// Schedule lists when n's remaining sends will go out, jitter included, assuming
+// each is delivered on time and nothing pauses or mutes it; at most limit of them
+func (w *Worker) Schedule(n *model.Notification, settings model.Settings, limit int) []time.Time {
+	sim := *n
+	var times []time.Time
+	for len(times) < limit && w.inSeries(&sim, settings) {
+		t := w.NextSendTime(&sim, settings)
+		times = append(times, t)
+		sim.SendsCount++
+		sim.LastPushTime = t
+		sim.History = []model.SendAttempt{{Time: t, OK: true}}
+	}
+	return times
+}
+
+// NextSendTime returns when n's next send falls due, including any jitter. Only the
+// jitter and holidays are taken from settings.
+func (w *Worker) NextSendTime(n *model.Notification, settings model.Settings) time.Time {
+	return w.dueTime(n, settings).Add(jitterOffset(n.ID, n.SendsCount, settings.JitterSeconds))
+}
+
+// dueTime is when n's next send falls due, before jitter: its place in the series, but
+// no sooner than an interval after the previous send. Repeats left in the past, by an
+// edit moving the schedule back or by the worker being down, then go out one at a
+// time rather than in a burst. A send that failed temporarily waits until RetryAt, and
+// a snoozed one until SnoozedUntil. A send outside n's send window waits for it to
+// open, and one falling on a weekend or holiday when n is weekdays-only waits for the
+// next working day; later repeats follow on an interval after it. A moved send is
+// still the same send of the series, so it counts toward the total as usual.
+func (w *Worker) dueTime(n *model.Notification, settings model.Settings) time.Time {
+	due := w.sendTime(n, n.SendsCount)
+	if n.SendsCount > 0 {
+		if last := n.LastSentAt(); !last.IsZero() {
+			// Measure from the previous send's slot, so its jitter doesn't carry over
+			slot := last.Add(-jitterOffset(n.ID, n.SendsCount-1, settings.JitterSeconds)).Truncate(w.precision)
+			if earliest := addIntervals(n, slot, 1); earliest.After(due) {
+				due = earliest
+			}
 		}
 	}
+	if n.RetryAt.After(due) {
+		due = n.RetryAt
+	}
+	if n.SnoozedUntil.After(due) {
+		due = n.SnoozedUntil
+	}
+	due = n.NextInSendWindow(due)
+	if n.WeekdaysOnly {
+		due = n.NextWorkday(due, settings.Holidays)
+	}
+	return due
+}
+
+// sendTime is when send number k (0-based) of n falls due in its series, before jitter.
+// Repeats are counted from the scheduled time rather than the last send, so they all
+// stay on the minute (or the second when keeping seconds).
+func (w *Worker) sendTime(n *model.Notification, k int) time.Time {
+	return addIntervals(n, n.ScheduledTime.Truncate(w.precision), k)
+}
 
+// addIntervals returns t plus k of n's repeat intervals. Intervals in days step by
+// calendar day in the server's time zone, so a daily 9:00 reminder stays at 9:00
+// across daylight saving changes; other intervals are fixed durations.
+func addIntervals(n *model.Notification, t time.Time, k int) time.Time {
+	if days, ok := model.IntervalDays(n.RepeatInterval); ok {
+		return t.In(time.Local).AddDate(0, 0, days*k)
+	}
+	return t.Add(repeatInterval(n) * time.Duration(k))
+}
+
+// repeatInterval is the time between n's sends, 30 minutes if it isn't a valid
+// interval (see storage's Validate)
+func repeatInterval(n *model.Notification) time.Duration {
+	interval, err := model.ParseInterval(n.RepeatInterval)
+	if err != nil || interval <= 0 {
+		return 30 * time.Minute
+	}
+	return interval
+}
+
+// deleteExpired removes Done notifications whose AutoDeleteAfter has run out and
+// returns when the next one is due to go, or zero if none are waiting
+func (w *Worker) deleteExpired() time.Time {
+	now := time.Now()
+	deleted := false
+	var earliestNext time.Time
+
+	for _, n := range w.store.GetAllNotifications() {
+		if n.Status != model.StatusDone || n.AutoDeleteAfter <= 0 {
+			continue
+		}
+		deleteAt := n.DoneAt().Add(n.AutoDeleteAfter)
+		if now.Before(deleteAt) {
+			if earliestNext.IsZero() || deleteAt.Before(earliestNext) {
+				earliestNext = deleteAt
+			}
+			continue
+		}
+		if err := w.store.DeleteNotification(n.ID, "worker"); err != nil {
+			slog.Error("Failed to auto-delete notification", "id", n.ID, "error", err)
+			continue
+		}
+		slog.Info("Notification auto-deleted", "id", n.ID, "content", n.Content)
+		deleted = true
+	}
+
+	if deleted && w.onUpdate != nil {
+		w.onUpdate()
+	}
 	return earliestNext
 }
+
+// BuildMessage assembles the next message sent for n, apart from the image
+// attachment, which is only fetched at send time
+func (w *Worker) BuildMessage(n *model.Notification, settings model.Settings) pushover.Message {
+	title := settings.DefaultTitle
+	if title == "" {
+		title = "Reminder"
+	}
+	if settings.InstanceName != "" {
+		title = "[" + settings.InstanceName + "] " + title
+	}
+	content, err := n.RenderContent()
+	if err != nil {
+		// Templates are checked on save, so this takes a data file edited by hand
+		slog.Warn("Failed to render content template, sending it as written", "id", n.ID, "error", err)
+		content = n.Content
+	}
+	msg := pushover.Message{Title: title, Message: content, Priority: sendPriority(n, n.SendsCount), Sound: settings.Sound}
+	w.configMu.RLock()
+	ackBaseURL := w.ackBaseURL
+	replies := w.replies
+	w.configMu.RUnlock()
+	if replies && n.ReplyToken != "" {
+		msg.Message += "\n\n" + replyRefPrefix + n.ReplyToken
+	}
+	if n.AckToken != "" && ackBaseURL != "" {
+		msg.URL = ackBaseURL + "/ack/" + n.AckToken
+		msg.URLTitle = "Acknowledge"
+	}
+	if msg.Priority == pushover.PriorityEmergency && ackBaseURL != "" {
+		// Acknowledging in the Pushover app marks the notification Done too
+		msg.Callback = ackBaseURL + "/pushover/callback"
+	}
+	if mapURL := n.MapURL(); mapURL != "" {
+		linkTitle := n.Place
+		if linkTitle == "" {
+			linkTitle = "Open map"
+		}
+		if msg.URL == "" {
+			msg.URL, msg.URLTitle = mapURL, linkTitle
+		} else {
+			// Messages carry one supplementary URL, taken by the acknowledge link, so link
+			// the map from the text instead
+			msg.Message += "\n" + `<a href="` + html.EscapeString(mapURL) + `">` + html.EscapeString(linkTitle) + `</a>`
+		}
+	}
+	return msg
+}
+
+// SendDirect sends msg right away with the saved credentials, outside any notification
+func (w *Worker) SendDirect(msg pushover.Message) error {
+	settings := w.store.GetSettings()
+	if settings.PushoverToken == "" || settings.PushoverUser == "" {
+		return ErrNoCredentials
+	}
+	w.configMu.RLock()
+	client := &pushover.Client{Token: settings.PushoverToken, User: settings.PushoverUser, BaseURL: w.apiBaseURL, HTTPClient: w.httpClient}
+	w.configMu.RUnlock()
+	if err := w.limiter.wait(context.Background()); err != nil {
+		return err
+	}
+	return client.Send(msg)
+}
+
+// SendCorrection sends n's current content, with "Updated: " before the title, after
+// an edit to a notification already sent; Pushover can't change delivered messages.
+// It goes at normal priority, so a correction never sounds an emergency alarm.
+func (w *Worker) SendCorrection(n *model.Notification, actor string) error {
+	msg := w.BuildMessage(n, w.store.GetSettings())
+	msg.Title = "Updated: " + msg.Title
+	msg.Priority = pushover.PriorityNormal
+	if err := w.SendDirect(msg); err != nil {
+		w.store.AppendAudit(model.AuditEvent{Action: model.AuditSendFailed, NotificationID: n.ID, Content: n.Content, Actor: actor, Detail: "correction: " + pushover.ErrorMessage(err)})
+		return err
+	}
+	w.store.AppendAudit(model.AuditEvent{Action: model.AuditSend, NotificationID: n.ID, Content: n.Content, Actor: actor, Detail: "correction"})
+	return nil
+}
+
+// sendPriority picks the priority of send number k (0-based), stepping through
+// the notification's escalation schedule if it has one
+func sendPriority(n *model.Notification, k int) int {
+	if len(n.Escalation) == 0 {
+		return n.Priority
+	}
+	return n.Escalation[min(k, len(n.Escalation)-1)]
+}
+
+// jitterOffset spreads sends that fall due in the same minute over up to maxSeconds.
+// It is derived from the notification and attempt rather than random, so it stays
+// the same each time the worker recomputes the schedule.
+func jitterOffset(id string, attempt, maxSeconds int) time.Duration {
+	if maxSeconds <= 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%d", id, attempt)
+	return time.Duration(h.Sum32()%uint32(maxSeconds*1000)) * time.Millisecond
+}
@@ -0,0 +1,625 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahxzhu/pushover-notify/internal/model"
+	"github.com/noahxzhu/pushover-notify/internal/pushover/pushovertest"
+	"github.com/noahxzhu/pushover-notify/internal/storage"
+)
+
+// newTestWorker returns a worker on an in-memory store whose messages go to a fake
+// Pushover API, with credentials saved
+func newTestWorker(t *testing.T) (*Worker, storage.Backend, *pushovertest.Server) {
+	t.Helper()
+	api := pushovertest.NewServer()
+	t.Cleanup(api.Close)
+
+	store := storage.NewInMemoryStore()
+	settings := store.GetSettings()
+	settings.PushoverToken, settings.PushoverUser = "app-token", "user-key"
+	if err := store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	w := NewWorker(store)
+	w.SetAPIBaseURL(api.BaseURL())
+	return w, store, api
+}
+
+// addNotification stores n, failing the test on error
+func addNotification(t *testing.T, store storage.Backend, n *model.Notification) *model.Notification {
+	t.Helper()
+	if n.Status == "" {
+		n.Status = model.StatusPending
+	}
+	if err := store.AddNotification(n, "test"); err != nil {
+		t.Fatalf("AddNotification: %v", err)
+	}
+	stored, err := store.GetNotification(n.ID)
+	if err != nil {
+		t.Fatalf("GetNotification: %v", err)
+	}
+	return stored
+}
+
+// reload returns n as it now stands in the store. The worker saves its changes as
+// copies, so the notification a test added doesn't change.
+func reload(t *testing.T, store storage.Backend, n *model.Notification) *model.Notification {
+	t.Helper()
+	stored, err := store.GetNotification(n.ID)
+	if err != nil {
+		t.Fatalf("GetNotification: %v", err)
+	}
+	return stored
+}
+
+func TestDueNotificationIsSentOnce(t *testing.T) {
+	w, store, api := newTestWorker(t)
+	n := addNotification(t, store, &model.Notification{
+		ID:             "due",
+		Content:        "Water the plants",
+		ScheduledTime:  time.Now().Add(-time.Second),
+		TotalSends:     2,
+		RepeatInterval: "1h",
+	})
+
+	// The second pass comes before the repeat is due, so it sends nothing
+	w.checkAndProcess(context.Background())
+	w.checkAndProcess(context.Background())
+
+	requests := api.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(requests))
+	}
+	want := map[string]string{
+		"token":   "app-token",
+		"user":    "user-key",
+		"title":   "Reminder",
+		"message": "Water the plants",
+		"html":    "1",
+	}
+	for field, value := range want {
+		if got := requests[0].Get(field); got != value {
+			t.Errorf("%s = %q, want %q", field, got, value)
+		}
+	}
+	if n = reload(t, store, n); n.SendsCount != 1 || n.Status != model.StatusPending {
+		t.Errorf("after send: SendsCount = %d, Status = %s; want 1, Pending", n.SendsCount, n.Status)
+	}
+}
+
+func TestFailedSendKeepsSendsCount(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, `{"status":0,"errors":["internal error"]}`},
+		{"rate limited", http.StatusTooManyRequests, `{"status":0,"errors":["message limit reached"]}`},
+		{"invalid credentials", http.StatusBadRequest, `{"user":"invalid","errors":["user identifier is invalid"],"status":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, store, api := newTestWorker(t)
+			api.SetResponse(tt.status, tt.body)
+			n := addNotification(t, store, &model.Notification{
+				ID:             "failing",
+				Content:        "Call back",
+				ScheduledTime:  time.Now().Add(-time.Second),
+				TotalSends:     3,
+				RepeatInterval: "30m",
+			})
+
+			w.checkAndProcess(context.Background())
+
+			if len(api.Requests()) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(api.Requests()))
+			}
+			if n = reload(t, store, n); n.SendsCount != 0 {
+				t.Errorf("SendsCount = %d after a failed send, want 0", n.SendsCount)
+			}
+			if n.LastError == "" {
+				t.Error("LastError not set after a failed send")
+			}
+		})
+	}
+}
+
+func TestEditDuringSendKeepsTheSend(t *testing.T) {
+	w, store, api := newTestWorker(t)
+	n := addNotification(t, store, &model.Notification{
+		ID:             "edited",
+		Content:        "Water the plants",
+		ScheduledTime:  time.Now().Add(-time.Second),
+		TotalSends:     2,
+		RepeatInterval: "1h",
+	})
+	// The edit is saved while the message is on its way
+	api.SetOnRequest(func() {
+		err := store.WithTransaction(func(tx *storage.Tx) error {
+			current, err := tx.GetNotification(n.ID)
+			if err != nil {
+				return err
+			}
+			updated := *current
+			updated.Content = "Water the garden"
+			return tx.UpdateNotification(&updated, "test")
+		})
+		if err != nil {
+			t.Errorf("edit: %v", err)
+		}
+	})
+
+	w.checkAndProcess(context.Background())
+	api.SetOnRequest(nil)
+	w.checkAndProcess(context.Background())
+
+	if got := len(api.Requests()); got != 1 {
+		t.Errorf("sent %d messages, want 1", got)
+	}
+	n = reload(t, store, n)
+	if n.Content != "Water the garden" {
+		t.Errorf("Content = %q, want the edit kept", n.Content)
+	}
+	if n.SendsCount != 1 || len(n.History) != 1 {
+		t.Errorf("SendsCount = %d, %d attempts recorded; want the send kept", n.SendsCount, len(n.History))
+	}
+}
+
+// TestFindOverdueWhileSending has the watchdog look for overdue notifications while
+// the worker sends them; run with -race, it checks they don't share any data
+func TestFindOverdueWhileSending(t *testing.T) {
+	w, store, api := newTestWorker(t)
+	for i := range 10 {
+		addNotification(t, store, &model.Notification{
+			ID:             fmt.Sprintf("overdue-%d", i),
+			Content:        "Overdue",
+			ScheduledTime:  time.Now().Add(-time.Hour),
+			TotalSends:     3,
+			RepeatInterval: "1h",
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			w.findOverdue(store.GetSettings(), time.Now(), time.Minute)
+		}
+	}()
+	w.checkAndProcess(context.Background())
+	<-done
+
+	if got := len(api.Requests()); got != 10 {
+		t.Errorf("sent %d messages, want 10", got)
+	}
+	if count, _, _ := w.findOverdue(store.GetSettings(), time.Now(), time.Minute); count != 0 {
+		t.Errorf("%d overdue after sending them all", count)
+	}
+}
+
+// inLocation runs the rest of the test with time.Local set to name, as if the server
+// ran in that time zone
+func inLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	saved := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = saved })
+	return loc
+}
+
+func TestSendTimeAcrossDST(t *testing.T) {
+	berlin := inLocation(t, "Europe/Berlin")
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, berlin)
+	}
+	// In 2026 Berlin springs forward on Sunday 29 March and falls back on Sunday 25 October
+	tests := []struct {
+		name      string
+		scheduled time.Time
+		interval  string
+		wantWall  string        // Local time of the first repeat
+		wantGap   time.Duration // Real time between the first send and the repeat
+	}{
+		{"1d into spring forward", at(time.March, 28, 9), "1d", "2026-03-29 09:00", 23 * time.Hour},
+		{"1d out of spring forward", at(time.March, 29, 9), "1d", "2026-03-30 09:00", 24 * time.Hour},
+		{"2h over spring forward", at(time.March, 29, 1), "2h", "2026-03-29 04:00", 2 * time.Hour},
+		{"1d into fall back", at(time.October, 24, 9), "1d", "2026-10-25 09:00", 25 * time.Hour},
+		{"1d out of fall back", at(time.October, 25, 9), "1d", "2026-10-26 09:00", 24 * time.Hour},
+		{"2h over fall back", at(time.October, 25, 1), "2h", "2026-10-25 02:00", 2 * time.Hour},
+	}
+	w := NewWorker(storage.NewInMemoryStore())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &model.Notification{ID: "dst", ScheduledTime: tt.scheduled, TotalSends: 3, RepeatInterval: tt.interval}
+			first, repeat := w.sendTime(n, 0), w.sendTime(n, 1)
+			if got := repeat.In(berlin).Format("2006-01-02 15:04"); got != tt.wantWall {
+				t.Errorf("repeat at %s, want %s", got, tt.wantWall)
+			}
+			if gap := repeat.Sub(first); gap != tt.wantGap {
+				t.Errorf("repeat %s after the first send, want %s", gap, tt.wantGap)
+			}
+		})
+	}
+}
+
+// formatTimes formats ts in the server's time zone, for comparing schedules
+func formatTimes(ts []time.Time) []string {
+	var out []string
+	for _, t := range ts {
+		out = append(out, t.In(time.Local).Format("Mon 2006-01-02 15:04"))
+	}
+	return out
+}
+
+// checkSchedule compares the first len(want) sends of n's schedule with want
+func checkSchedule(t *testing.T, w *Worker, n *model.Notification, want []string) {
+	t.Helper()
+	got := formatTimes(w.Schedule(n, w.store.GetSettings(), len(want)))
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("schedule = %q, want %q", got, want)
+	}
+}
+
+func TestWeekdaysOnly(t *testing.T) {
+	berlin := inLocation(t, "Europe/Berlin")
+	w := NewWorker(storage.NewInMemoryStore())
+	tests := []struct {
+		name      string
+		scheduled time.Time
+		interval  string
+		want      []string
+	}{
+		{"Friday stays", time.Date(2026, time.October, 16, 9, 0, 0, 0, berlin), "1h",
+			[]string{"Fri 2026-10-16 09:00", "Fri 2026-10-16 10:00"}},
+		{"Saturday to Monday", time.Date(2026, time.October, 17, 9, 0, 0, 0, berlin), "1h",
+			[]string{"Mon 2026-10-19 09:00", "Mon 2026-10-19 10:00"}},
+		{"Sunday to Monday", time.Date(2026, time.October, 18, 9, 0, 0, 0, berlin), "1h",
+			[]string{"Mon 2026-10-19 09:00", "Mon 2026-10-19 10:00"}},
+		{"daily from Friday skips the weekend", time.Date(2026, time.October, 16, 9, 0, 0, 0, berlin), "1d",
+			[]string{"Fri 2026-10-16 09:00", "Mon 2026-10-19 09:00", "Tue 2026-10-20 09:00"}},
+		{"hourly running into Saturday", time.Date(2026, time.October, 16, 23, 0, 0, 0, berlin), "1h",
+			[]string{"Fri 2026-10-16 23:00", "Mon 2026-10-19 00:00", "Mon 2026-10-19 01:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &model.Notification{ID: "weekdays", ScheduledTime: tt.scheduled, TotalSends: len(tt.want), RepeatInterval: tt.interval, WeekdaysOnly: true}
+			checkSchedule(t, w, n, tt.want)
+		})
+	}
+}
+
+func TestWeekdaysOnlyMultiDayHoliday(t *testing.T) {
+	berlin := inLocation(t, "Europe/Berlin")
+	tests := []struct {
+		name      string
+		holidays  []string
+		scheduled time.Time
+		want      []string
+	}{
+		// Thursday and Friday off, then the weekend
+		{"into a weekend", []string{"2026-12-24", "2026-12-25"}, time.Date(2026, time.December, 23, 9, 0, 0, 0, berlin),
+			[]string{"Wed 2026-12-23 09:00", "Mon 2026-12-28 09:00", "Tue 2026-12-29 09:00"}},
+		{"starting on a holiday", []string{"2026-12-24", "2026-12-25"}, time.Date(2026, time.December, 24, 9, 0, 0, 0, berlin),
+			[]string{"Mon 2026-12-28 09:00", "Tue 2026-12-29 09:00"}},
+		// Good Friday to Easter Monday
+		{"Friday into Monday", []string{"2026-04-03", "2026-04-06"}, time.Date(2026, time.April, 2, 9, 0, 0, 0, berlin),
+			[]string{"Thu 2026-04-02 09:00", "Tue 2026-04-07 09:00", "Wed 2026-04-08 09:00"}},
+		{"starting on the Friday", []string{"2026-04-03", "2026-04-06"}, time.Date(2026, time.April, 3, 9, 0, 0, 0, berlin),
+			[]string{"Tue 2026-04-07 09:00", "Wed 2026-04-08 09:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewInMemoryStore()
+			settings := store.GetSettings()
+			settings.Holidays = tt.holidays
+			if err := store.UpdateSettings(settings); err != nil {
+				t.Fatalf("UpdateSettings: %v", err)
+			}
+			w := NewWorker(store)
+			n := &model.Notification{ID: "holiday", ScheduledTime: tt.scheduled, TotalSends: len(tt.want), RepeatInterval: "1d", WeekdaysOnly: true}
+			checkSchedule(t, w, n, tt.want)
+		})
+	}
+}
+
+func TestSendWindowAcrossMidnight(t *testing.T) {
+	berlin := inLocation(t, "Europe/Berlin")
+	w := NewWorker(storage.NewInMemoryStore())
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, time.October, day, hour, 0, 0, 0, berlin)
+	}
+	tests := []struct {
+		name      string
+		scheduled time.Time
+		want      []string
+	}{
+		{"inside, before midnight", at(13, 23),
+			[]string{"Tue 2026-10-13 23:00", "Wed 2026-10-14 02:00", "Wed 2026-10-14 05:00", "Wed 2026-10-14 22:00"}},
+		{"inside, after midnight", at(14, 2),
+			[]string{"Wed 2026-10-14 02:00", "Wed 2026-10-14 05:00", "Wed 2026-10-14 22:00"}},
+		{"before the window opens", at(13, 21),
+			[]string{"Tue 2026-10-13 22:00", "Wed 2026-10-14 01:00"}},
+		{"after the window closes", at(14, 7),
+			[]string{"Wed 2026-10-14 22:00", "Thu 2026-10-15 01:00"}},
+		{"as the window closes", at(14, 6),
+			[]string{"Wed 2026-10-14 22:00", "Thu 2026-10-15 01:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &model.Notification{
+				ID:              "window",
+				ScheduledTime:   tt.scheduled,
+				TotalSends:      len(tt.want),
+				RepeatInterval:  "3h",
+				SendWindowStart: "22:00",
+				SendWindowEnd:   "06:00",
+			}
+			checkSchedule(t, w, n, tt.want)
+		})
+	}
+}
+
+func TestStopOnFirstDelivery(t *testing.T) {
+	w, store, api := newTestWorker(t)
+	n := addNotification(t, store, &model.Notification{
+		ID:                  "once",
+		Content:             "Parcel delivered",
+		ScheduledTime:       time.Now().Add(-time.Hour),
+		TotalSends:          5,
+		RepeatInterval:      "1s",
+		StopOnFirstDelivery: true,
+	})
+
+	w.checkAndProcess(context.Background())
+	if n = reload(t, store, n); n.Status != model.StatusDone || n.SendsCount != 1 {
+		t.Fatalf("after one delivery: Status = %s, SendsCount = %d; want Done, 1", n.Status, n.SendsCount)
+	}
+	w.checkAndProcess(context.Background())
+	if got := len(api.Requests()); got != 1 {
+		t.Errorf("sent %d messages, want 1", got)
+	}
+}
+
+func TestSeriesEnd(t *testing.T) {
+	inLocation(t, "UTC")
+	scheduled := time.Date(2026, time.October, 16, 9, 0, 0, 0, time.UTC)
+	w := NewWorker(storage.NewInMemoryStore())
+	tests := []struct {
+		name string
+		n    model.Notification
+		want []string
+	}{
+		{"count limit", model.Notification{TotalSends: 3},
+			[]string{"Fri 2026-10-16 09:00", "Fri 2026-10-16 10:00", "Fri 2026-10-16 11:00"}},
+		{"repeat until, on a send", model.Notification{TotalSends: 99, RepeatUntil: scheduled.Add(2 * time.Hour)},
+			[]string{"Fri 2026-10-16 09:00", "Fri 2026-10-16 10:00", "Fri 2026-10-16 11:00"}},
+		{"repeat until, between sends", model.Notification{TotalSends: 99, RepeatUntil: scheduled.Add(90 * time.Minute)},
+			[]string{"Fri 2026-10-16 09:00", "Fri 2026-10-16 10:00"}},
+		{"repeat until before the first send", model.Notification{RepeatUntil: scheduled.Add(-time.Hour)},
+			[]string{"Fri 2026-10-16 09:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := tt.n
+			n.ID, n.ScheduledTime, n.RepeatInterval = "series", scheduled, "1h"
+			// Ask for more than the series has: the schedule must stop by itself
+			got := formatTimes(w.Schedule(&n, model.Settings{}, len(tt.want)+5))
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("schedule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesEndMarksDone(t *testing.T) {
+	w, store, api := newTestWorker(t)
+	started := time.Now().Add(-3 * time.Hour).Truncate(time.Minute)
+	last := &model.Notification{
+		ID: "last", Content: "Last of three", ScheduledTime: started, TotalSends: 3, RepeatInterval: "1h",
+		SendsCount: 2, LastPushTime: started.Add(time.Hour),
+	}
+	expired := &model.Notification{
+		ID: "expired", Content: "Until an hour ago", ScheduledTime: started, TotalSends: 99, RepeatInterval: "1h",
+		RepeatUntil: started.Add(90 * time.Minute), SendsCount: 2, LastPushTime: started.Add(time.Hour),
+	}
+	last, expired = addNotification(t, store, last), addNotification(t, store, expired)
+
+	w.checkAndProcess(context.Background())
+
+	last, expired = reload(t, store, last), reload(t, store, expired)
+	if last.SendsCount != 3 || last.Status != model.StatusDone {
+		t.Errorf("count limit: SendsCount = %d, Status = %s; want 3, Done", last.SendsCount, last.Status)
+	}
+	if expired.SendsCount != 2 || expired.Status != model.StatusDone {
+		t.Errorf("repeat until: SendsCount = %d, Status = %s; want 2, Done", expired.SendsCount, expired.Status)
+	}
+	if got := len(api.Requests()); got != 1 {
+		t.Errorf("sent %d messages, want 1", got)
+	}
+}
+
+func TestExpiredDoneNotificationsAreDeleted(t *testing.T) {
+	w, store, _ := newTestWorker(t)
+	now := time.Now()
+	addNotification(t, store, &model.Notification{
+		ID: "expired", Status: model.StatusDone, LastPushTime: now.Add(-2 * time.Hour), AutoDeleteAfter: time.Hour,
+	})
+	addNotification(t, store, &model.Notification{
+		ID: "expiring", Status: model.StatusDone, LastPushTime: now.Add(-time.Hour + 300*time.Millisecond), AutoDeleteAfter: time.Hour,
+	})
+	addNotification(t, store, &model.Notification{
+		ID: "kept", Status: model.StatusDone, LastPushTime: now, AutoDeleteAfter: time.Hour,
+	})
+
+	ticks := make(chan struct{}, 10)
+	w.SetOnTick(func() { ticks <- struct{}{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	remaining := func() []string {
+		var ids []string
+		for _, n := range store.GetAllNotifications() {
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+	waitTick := func() {
+		t.Helper()
+		select {
+		case <-ticks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no scheduling pass within 5s")
+		}
+	}
+
+	waitTick()
+	if got := strings.Join(remaining(), ","); got != "expiring,kept" {
+		t.Fatalf("after the first pass: %s remain, want expiring,kept", got)
+	}
+	// The worker wakes up for the next deletion without being refreshed
+	waitTick()
+	if got := strings.Join(remaining(), ","); got != "kept" {
+		t.Errorf("after the next pass: %s remain, want kept", got)
+	}
+}
+
+func TestSendModeCounts(t *testing.T) {
+	w := NewWorker(storage.NewInMemoryStore())
+	tests := []struct {
+		mode       string
+		totalSends int
+		want       int
+	}{
+		{model.SendModeTotal, 1, 1},
+		{model.SendModeTotal, 3, 3},
+		{"", 3, 3}, // Total is the default
+		{model.SendModeRepeats, 1, 2},
+		{model.SendModeRepeats, 3, 4},
+		{model.SendModeTotal, 0, 3}, // Legacy data without a count
+		{model.SendModeRepeats, 0, 4},
+	}
+	for _, tt := range tests {
+		n := &model.Notification{ID: "mode", ScheduledTime: time.Now(), TotalSends: tt.totalSends, RepeatInterval: "1h"}
+		settings := model.Settings{SendMode: tt.mode}
+		if got := len(w.Schedule(n, settings, 100)); got != tt.want {
+			t.Errorf("mode %q, total_sends %d: %d sends, want %d", tt.mode, tt.totalSends, got, tt.want)
+		}
+		// The last send of the series ends it
+		n.SendsCount = tt.want - 1
+		if !w.inSeries(n, settings) {
+			t.Errorf("mode %q, total_sends %d: send %d not in the series", tt.mode, tt.totalSends, tt.want)
+		}
+		n.SendsCount = tt.want
+		if w.inSeries(n, settings) {
+			t.Errorf("mode %q, total_sends %d: send %d still in the series", tt.mode, tt.totalSends, tt.want+1)
+		}
+	}
+}
+
+// startWorker runs w until the test ends and returns a channel that receives after
+// each scheduling pass
+func startWorker(t *testing.T, w *Worker) <-chan struct{} {
+	t.Helper()
+	ticks := make(chan struct{}, 10)
+	w.SetOnTick(func() { ticks <- struct{}{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return ticks
+}
+
+// waitPass waits for the next scheduling pass
+func waitPass(t *testing.T, ticks <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ticks:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no scheduling pass within 5s")
+	}
+}
+
+// setCredentials saves Pushover credentials and refreshes the worker, as saving the
+// settings page does
+func setCredentials(t *testing.T, w *Worker, store storage.Backend, token, user string) {
+	t.Helper()
+	settings := store.GetSettings()
+	settings.PushoverToken, settings.PushoverUser = token, user
+	if err := store.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	w.Refresh()
+}
+
+func TestCredentialsWakeWorker(t *testing.T) {
+	t.Run("added after starting without", func(t *testing.T) {
+		w, store, api := newTestWorker(t)
+		settings := store.GetSettings()
+		settings.PushoverToken, settings.PushoverUser = "", ""
+		if err := store.UpdateSettings(settings); err != nil {
+			t.Fatalf("UpdateSettings: %v", err)
+		}
+		n := addNotification(t, store, &model.Notification{
+			ID: "waiting", Content: "Waiting for credentials", ScheduledTime: time.Now().Add(-time.Minute), TotalSends: 2, RepeatInterval: "1h",
+		})
+		ticks := startWorker(t, w)
+		waitPass(t, ticks)
+		if got := len(api.Requests()); got != 0 {
+			t.Fatalf("sent %d messages without credentials", got)
+		}
+
+		setCredentials(t, w, store, "app-token", "user-key")
+		waitPass(t, ticks)
+		if got := len(api.Requests()); got != 1 {
+			t.Fatalf("sent %d messages after credentials were saved, want 1", got)
+		}
+		if n = reload(t, store, n); n.SendsCount != 1 {
+			t.Errorf("SendsCount = %d, want 1", n.SendsCount)
+		}
+	})
+
+	t.Run("replacing rejected ones", func(t *testing.T) {
+		w, store, api := newTestWorker(t)
+		api.SetResponse(http.StatusBadRequest, `{"token":"invalid","errors":["application token is invalid"],"status":0}`)
+		addNotification(t, store, &model.Notification{
+			ID: "rejected", Content: "Rejected at first", ScheduledTime: time.Now().Add(-time.Minute), TotalSends: 2, RepeatInterval: "1h",
+		})
+		ticks := startWorker(t, w)
+		waitPass(t, ticks)
+		if w.Status().CredentialsError == "" {
+			t.Fatal("no credentials error after Pushover rejected the token")
+		}
+		// Parked until the next retry, a quarter of an hour away
+		if next := w.Status().NextRun; time.Until(next) < credentialsWarnInterval-time.Minute {
+			t.Fatalf("next run in %s, want the credentials retry", time.Until(next))
+		}
+
+		api.SetResponse(http.StatusOK, `{"status":1,"request":"ok"}`)
+		setCredentials(t, w, store, "new-app-token", "user-key")
+		waitPass(t, ticks)
+		requests := api.Requests()
+		if len(requests) != 2 || requests[1].Get("token") != "new-app-token" {
+			t.Fatalf("after saving new credentials: %d requests, want a second one with the new token", len(requests))
+		}
+		if got := w.Status().CredentialsError; got != "" {
+			t.Errorf("CredentialsError = %q after a successful send", got)
+		}
+	})
+}